@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wazp/c64dreams-tool/internal/executor"
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/internal/planfile"
+)
+
+func newApplyCmd(opts *options) *cobra.Command {
+	var planPath string
+
+	cmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Execute a plan file written by plan",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOptions(opts); err != nil {
+				return err
+			}
+
+			if planPath == "" {
+				return fmt.Errorf("--plan is required")
+			}
+
+			if opts.input == "" {
+				return fmt.Errorf("--input is required")
+			}
+
+			if opts.output == "" {
+				return fmt.Errorf("--output is required")
+			}
+
+			pf, err := planfile.Read(planPath)
+			if err != nil {
+				return err
+			}
+
+			// the sheet is optional; when given it must be the one the plan was made from
+			if opts.sheet != "" {
+				checksum, err := planfile.SheetChecksum(opts.sheet)
+				if err != nil {
+					return err
+				}
+				if checksum != pf.SheetSHA256 {
+					return fmt.Errorf("sheet %s does not match the plan (plan sha256 %s, sheet sha256 %s); re-run plan", opts.sheet, pf.SheetSHA256, checksum)
+				}
+			}
+
+			journal, err := startJournal(opts)
+			if err != nil {
+				return err
+			}
+
+			progress, endProgress := progressReporter(cmd, opts)
+			execOpts := executor.Options{
+				InputRoot:  opts.input,
+				OutputRoot: opts.output,
+				DryRun:     opts.dryRun,
+				Overwrite:  opts.overwrite,
+				Strict:     opts.strict,
+				KeepGoing:  opts.keepGoing,
+				Jobs:       opts.jobs,
+				Journal:    journal,
+				Progress:   progress,
+			}
+			results, execErr := executor.Apply(cmd.Context(), pf.Files, execOpts)
+
+			results, execErr = pruneOutput(cmd.Context(), opts, results, execOpts, execErr)
+			endProgress()
+			execErr = finishJournal(journal, execErr, opts.output)
+
+			if opts.json {
+				payload := struct {
+					Plan     []layout.PlannedFile `json:"plan"`
+					Results  []jsonResult         `json:"results"`
+					Failures map[string]int       `json:"failures,omitempty"`
+					Error    string               `json:"error,omitempty"`
+				}{
+					Plan:     pf.Files,
+					Results:  flattenResults(results),
+					Failures: failureCounts(execErr),
+				}
+				if execErr != nil {
+					payload.Error = execErr.Error()
+				}
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(payload); err != nil {
+					return err
+				}
+				if reportable(execErr) {
+					return execErr
+				}
+				return nil
+			}
+
+			if !reportable(execErr) {
+				return execErr
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Applying plan for %s with %d files\n", pf.Target, len(pf.Files))
+			for _, r := range results {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s -> %s (%s)\n", r.Source, r.Dest, r.Action)
+			}
+			reportCounts(cmd.OutOrStdout(), results)
+			reportRenamed(cmd.OutOrStdout(), results)
+			reportFailures(cmd.OutOrStdout(), execErr)
+			reportCancelled(cmd.OutOrStdout(), execErr)
+			reportPruneSkipped(cmd.OutOrStdout(), opts, execErr)
+			if opts.dryRun {
+				fmt.Fprintln(cmd.OutOrStdout(), "Dry-run enabled: no changes written")
+			}
+
+			return execErr
+		},
+	}
+
+	cmd.Flags().StringVar(&planPath, "plan", "", "Plan file written by plan --out")
+
+	return cmd
+}
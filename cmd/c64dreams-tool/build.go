@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
+	"github.com/wazp/c64dreams-tool/internal/cards"
 	"github.com/wazp/c64dreams-tool/internal/executor"
 	"github.com/wazp/c64dreams-tool/internal/ingest"
+	"github.com/wazp/c64dreams-tool/internal/launcher"
 	"github.com/wazp/c64dreams-tool/internal/layout"
 	"github.com/wazp/c64dreams-tool/internal/normalize"
 	"github.com/wazp/c64dreams-tool/pkg/model"
 )
 
 func newBuildCmd(opts *options) *cobra.Command {
+	var rollback bool
+
 	cmd := &cobra.Command{
 		Use:   "build",
 		Short: "Run ingest → normalize → collide → layout → execute",
@@ -22,6 +32,10 @@ func newBuildCmd(opts *options) *cobra.Command {
 				return err
 			}
 
+			if rollback {
+				return runRollback(cmd, opts)
+			}
+
 			if opts.sheet == "" {
 				return fmt.Errorf("--sheet is required")
 			}
@@ -34,68 +48,103 @@ func newBuildCmd(opts *options) *cobra.Command {
 				return fmt.Errorf("--output is required")
 			}
 
-			games, err := ingest.LoadCSV(cmd.Context(), opts.sheet)
+			run, err := planLayout(cmd, opts)
 			if err != nil {
 				return err
 			}
+			planned, excluded, layoutOpts := run.planned, run.excluded, run.layout
 
-			normOpts := normalize.Options{Target: opts.target, MaxNameLen: opts.maxNameLen}
-			var normalized []model.NormalizedGame
-			for _, g := range games {
-				ng, err := normalize.NormalizeGame(g, normOpts)
-				if err != nil {
-					return err
-				}
-				normalized = append(normalized, ng)
-			}
-
-			normalized = normalize.ResolveCollisions(normalized, normOpts)
-
-			layoutOpts := layout.Options{
-				GroupByMedia:    opts.groupMedia,
-				GroupByAlpha:    opts.groupAlpha,
-				AlphaBucketSize: opts.alphaSize,
-			}
-
-			planned, err := layout.Plan(normalized, layoutOpts)
+			journal, err := startJournal(opts)
 			if err != nil {
 				return err
 			}
 
+			progress, endProgress := progressReporter(cmd, opts)
 			execOpts := executor.Options{
 				InputRoot:  opts.input,
 				OutputRoot: opts.output,
 				DryRun:     opts.dryRun,
 				Overwrite:  opts.overwrite,
+				Strict:     opts.strict,
+				KeepGoing:  opts.keepGoing,
+				Jobs:       opts.jobs,
+				Journal:    journal,
+				Progress:   progress,
 			}
 
-			results, execErr := executor.Apply(planned, execOpts)
+			// the resolved plan holds every file's real destination, including flat games moved
+			// into a folder because their source has companions, so the plan, cards, launchers
+			// and prune all see the same paths
+			var results []executor.Result
+			var cardList []cards.Card
+			planned, resolveResults, execErr := resolvePlan(cmd.Context(), opts, planned)
+			// files that failed to resolve or that the target cannot open never reach the plan;
+			// keep them for the report
+			for _, r := range resolveResults {
+				if r.Action == "error" || r.Action == "unsupported" {
+					results = append(results, r)
+				}
+			}
+			if continued(execErr) && opts.cardSize != "" {
+				var packErr error
+				if planned, cardList, packErr = packCards(opts, planned); packErr != nil {
+					execErr = packErr
+				}
+			}
+			if continued(execErr) {
+				applied, applyErr := executor.Apply(cmd.Context(), planned, execOpts)
+				results = append(results, withMatchInfo(applied, resolveResults)...)
+				execErr = mergeFailures(execErr, applyErr, results)
+			}
+
+			results, execErr = pruneOutput(cmd.Context(), opts, results, execOpts, execErr)
+			endProgress()
+			execErr = finishJournal(journal, execErr, opts.output)
 
 			if opts.json {
 				payload := struct {
-					Plan    []layout.PlannedFile `json:"plan"`
-					Results []jsonResult         `json:"results"`
-					Error   string               `json:"error,omitempty"`
+					Plan     []layout.PlannedFile `json:"plan"`
+					Excluded []layout.Exclusion   `json:"excluded,omitempty"`
+					Results  []jsonResult         `json:"results"`
+					Failures map[string]int       `json:"failures,omitempty"`
+					Error    string               `json:"error,omitempty"`
 				}{
-					Plan:    planned,
-					Results: flattenResults(results),
+					Plan:     planned,
+					Excluded: excluded,
+					Results:  flattenResults(results),
+					Failures: failureCounts(execErr),
 				}
 				if execErr != nil {
 					payload.Error = execErr.Error()
 				}
 				enc := json.NewEncoder(cmd.OutOrStdout())
 				enc.SetIndent("", "  ")
-				return enc.Encode(payload)
+				if err := enc.Encode(payload); err != nil {
+					return err
+				}
+				if reportable(execErr) {
+					return execErr
+				}
+				return nil
 			}
 
-			if execErr != nil {
+			if !reportable(execErr) {
 				return execErr
 			}
 
+			reportMenuClashes(cmd.OutOrStdout(), run.normalized, run.displayLen)
 			fmt.Fprintf(cmd.OutOrStdout(), "Planned %d files\n", len(planned))
 			for _, r := range results {
 				fmt.Fprintf(cmd.OutOrStdout(), "%s -> %s (%s)\n", r.Source, r.Dest, r.Action)
 			}
+			reportCounts(cmd.OutOrStdout(), results)
+			reportUnsupported(cmd.OutOrStdout(), excluded, results, layoutOpts.Unsupported)
+			reportCards(cmd.OutOrStdout(), cardList)
+			reportReview(cmd.OutOrStdout(), results)
+			reportRenamed(cmd.OutOrStdout(), results)
+			reportFailures(cmd.OutOrStdout(), execErr)
+			reportCancelled(cmd.OutOrStdout(), execErr)
+			reportPruneSkipped(cmd.OutOrStdout(), opts, execErr)
 			if opts.dryRun {
 				fmt.Fprintln(cmd.OutOrStdout(), "Dry-run enabled: no changes written")
 			}
@@ -104,20 +153,462 @@ func newBuildCmd(opts *options) *cobra.Command {
 		},
 	}
 
+	cmd.Flags().BoolVar(&rollback, "rollback", false, "Undo the changes of an unfinished or failed run under --output (honours --dry-run)")
+
 	return cmd
 }
 
+// startJournal begins journaling a run that writes to the output; dry runs need no journal.
+func startJournal(opts *options) (*executor.Journal, error) {
+	if opts.dryRun {
+		return nil, nil
+	}
+	return executor.StartJournal(opts.output)
+}
+
+// finishJournal discards the journal of a run that finished cleanly or changed nothing, and
+// keeps it for --rollback otherwise, including after files failed in keep-going mode.
+func finishJournal(journal *executor.Journal, execErr error, output string) error {
+	if journal == nil {
+		return execErr
+	}
+	if execErr == nil || journal.Empty() {
+		if err := journal.Commit(); err != nil {
+			return errors.Join(execErr, err)
+		}
+		return execErr
+	}
+	if err := journal.Close(); err != nil {
+		return errors.Join(execErr, err)
+	}
+	return fmt.Errorf("%w (undo this run with: build --rollback --output %q --dry-run=false)", execErr, output)
+}
+
+// runRollback undoes the journaled run under --output.
+func runRollback(cmd *cobra.Command, opts *options) error {
+	if opts.output == "" {
+		return fmt.Errorf("--output is required")
+	}
+	results, err := executor.Rollback(opts.output, opts.dryRun)
+
+	if opts.json {
+		payload := struct {
+			Results []jsonResult `json:"results"`
+			Error   string       `json:"error,omitempty"`
+		}{Results: flattenResults(results)}
+		if err != nil {
+			payload.Error = err.Error()
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(payload); encErr != nil {
+			return encErr
+		}
+		return err
+	}
+
+	for _, r := range results {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s -> %s (%s)\n", r.Source, r.Dest, r.Action)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.dryRun {
+		fmt.Fprintf(cmd.OutOrStdout(), "%d changes to roll back\n", len(results))
+		fmt.Fprintln(cmd.OutOrStdout(), "Dry-run enabled: no changes written")
+		return nil
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Rolled back %d changes\n", len(results))
+	return nil
+}
+
+// layoutRun holds the output of the ingest → normalize → collide → layout steps.
+type layoutRun struct {
+	normalized []model.NormalizedGame
+	planned    []layout.PlannedFile
+	excluded   []layout.Exclusion
+	layout     layout.Options
+	displayLen int
+}
+
+// planLayout loads the sheet and plans the layout for the selected target.
+func planLayout(cmd *cobra.Command, opts *options) (layoutRun, error) {
+	sheet, err := ingest.LoadSheet(cmd.Context(), opts.sheet)
+	if err != nil {
+		return layoutRun{}, err
+	}
+	layoutOpts := layoutOptions(cmd, opts)
+	if missing := sheet.MissingColumns(layoutOpts.MetadataFields()); len(missing) > 0 {
+		// every game would land in "unknown"
+		return layoutRun{}, fmt.Errorf("the layout needs sheet columns that %s lacks: %s (check --group-by and --path-template)", opts.sheet, strings.Join(missing, ", "))
+	}
+
+	normOpts := normalize.Options{Target: opts.target, MaxNameLen: opts.maxNameLen}
+	var normalized []model.NormalizedGame
+	for _, g := range sheet.Games {
+		ng, err := normalize.NormalizeGame(g, normOpts)
+		if err != nil {
+			return layoutRun{}, err
+		}
+		normalized = append(normalized, ng)
+	}
+
+	normalized = normalize.ResolveCollisions(normalized, normOpts)
+
+	planned, err := layout.Plan(normalized, layoutOpts)
+	if err != nil {
+		return layoutRun{}, err
+	}
+
+	return layoutRun{
+		normalized: normalized,
+		planned:    planned,
+		excluded:   layout.Exclusions(normalized, layoutOpts),
+		layout:     layoutOpts,
+		displayLen: normOpts.EffectiveDisplayLen(),
+	}, nil
+}
+
+// layoutOptions builds layout options from flags, falling back to the target profile's
+// default layout for anything not set on the command line.
+func layoutOptions(cmd *cobra.Command, opts *options) layout.Options {
+	profile := model.ProfileFor(opts.target)
+	defaults := profile.Layout
+	layoutOpts := layout.Options{
+		BaseDir:          defaults.BaseDir,
+		MaxEntriesPerDir: profile.MaxEntriesPerDir,
+		GroupByMedia:     opts.groupMedia,
+		GroupByAlpha:     opts.groupAlpha,
+		AlphaBucketSize:  opts.alphaSize,
+		AlphaDepth:       opts.alphaDepth,
+		ScreenEntries:    opts.screenSize,
+		Unsupported:      layout.UnsupportedPolicy(opts.unsupported),
+		PathTemplate:     defaults.PathTemplate,
+		FilenameFlags:    defaults.FilenameFlags,
+		Flat:             defaults.Flat,
+		MediaDirs:        defaults.MediaDirs,
+	}
+	flags := cmd.Flags()
+	if opts.maxEntries > 0 {
+		layoutOpts.MaxEntriesPerDir = opts.maxEntries
+	}
+	if flags.Changed("path-template") {
+		layoutOpts.PathTemplate = opts.template
+	}
+	if !flags.Changed("group-media") {
+		layoutOpts.GroupByMedia = defaults.GroupByMedia
+	}
+	if !flags.Changed("group-alpha") {
+		layoutOpts.GroupByAlpha = defaults.GroupByAlpha
+	}
+	if !flags.Changed("alpha-bucket-size") && defaults.AlphaBucketSize > 0 {
+		layoutOpts.AlphaBucketSize = defaults.AlphaBucketSize
+	}
+	if len(opts.mediaDirs) > 0 {
+		merged := make(map[string]string, len(defaults.MediaDirs)+len(opts.mediaDirs))
+		for group, name := range defaults.MediaDirs {
+			merged[group] = name
+		}
+		for group, name := range opts.mediaDirs {
+			merged[group] = name
+		}
+		layoutOpts.MediaDirs = merged
+	}
+	if flags.Changed("flat") {
+		layoutOpts.Flat = opts.flat
+	}
+	if !flags.Changed("alpha-depth") {
+		layoutOpts.AlphaDepth = defaults.AlphaDepth
+	}
+
+	groupBy := defaults.GroupBy
+	if flags.Changed("group-by") {
+		groupBy = opts.groupBy
+	}
+	if len(groupBy) > 0 {
+		// validated up front by validateOptions and profile loading
+		groupings, _ := layout.ParseGroupings(groupBy)
+		layoutOpts.GroupBy = groupings
+		if len(groupings) == 0 {
+			layoutOpts.GroupByMedia = false
+			layoutOpts.GroupByAlpha = false
+		}
+	}
+	return layoutOpts
+}
+
+// resolvePlan resolves the plan into exact file operations and adds the target's launchers.
+func resolvePlan(ctx context.Context, opts *options, planned []layout.PlannedFile) ([]layout.PlannedFile, []executor.Result, error) {
+	// destinations are stored relative to the output root, so any root resolves the same plan
+	outputRoot := opts.output
+	if outputRoot == "" {
+		outputRoot = "."
+	}
+	resolveOpts := executor.Options{InputRoot: opts.input, OutputRoot: outputRoot, Strict: opts.strict, KeepGoing: opts.keepGoing, Jobs: opts.jobs}
+	resolved, results, err := executor.Resolve(ctx, planned, resolveOpts)
+	if !continued(err) {
+		return nil, results, err
+	}
+	generated, genErr := launcher.Generate(opts.target, planned, results, outputRoot)
+	if genErr != nil {
+		return nil, results, genErr
+	}
+	return append(resolved, generated...), results, err
+}
+
+// packCards splits a resolved plan across cards of the --card-size capacity and adds the card
+// index at the output root.
+func packCards(opts *options, resolved []layout.PlannedFile) ([]layout.PlannedFile, []cards.Card, error) {
+	// validated up front by validateOptions
+	capacity, _ := parseSize(opts.cardSize)
+	split, cardList, err := cards.Split(resolved, capacity, opts.clusterSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	split = append(split, layout.PlannedFile{
+		GameID: "cards",
+		Target: opts.target,
+		Title:  "Card index",
+		Path:   cards.IndexName,
+		Body:   cards.Index(cardList),
+	})
+	return split, cardList, nil
+}
+
+// reportCards prints how the output was split across cards.
+func reportCards(w io.Writer, cardList []cards.Card) {
+	for _, c := range cardList {
+		first, last := c.Games[0].Title, c.Games[len(c.Games)-1].Title
+		fmt.Fprintf(w, "%s: %d games, %d bytes (%s - %s)\n", c.Name, len(c.Games), c.Used, first, last)
+	}
+	if len(cardList) > 0 {
+		fmt.Fprintf(w, "Card index written to %s\n", cards.IndexName)
+	}
+}
+
+// reportReview lists sources that were found by an ambiguous or low-confidence fuzzy match.
+func reportReview(w io.Writer, results []executor.Result) {
+	var review []executor.Result
+	for _, r := range results {
+		// siblings share their primary's match; list each match once
+		if r.NeedsReview() && len(r.Candidates) > 0 && r.Source == r.Candidates[0].Path {
+			review = append(review, r)
+		}
+	}
+	if len(review) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Review %d fuzzy source matches:\n", len(review))
+	for _, r := range review {
+		fmt.Fprintf(w, "- %s <- %s (%s, confidence %.2f", r.Dest, r.Source, r.Strategy, r.Confidence)
+		if len(r.Candidates) > 1 {
+			fmt.Fprintf(w, ", %d candidates, next %s %.2f", len(r.Candidates)+r.MoreCandidates, r.Candidates[1].Path, r.Candidates[1].Score)
+		}
+		fmt.Fprintln(w, ")")
+	}
+}
+
+// reportRenamed lists files that were given a new name because another source already claimed
+// their destination in the same run.
+func reportRenamed(w io.Writer, results []executor.Result) {
+	var renamed []executor.Result
+	seen := make(map[string]bool)
+	for _, r := range results {
+		// variants sharing a folder rename the same sibling; list it once
+		if r.RenamedFrom != "" && !seen[r.Dest] {
+			seen[r.Dest] = true
+			renamed = append(renamed, r)
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Renamed %d files whose destination clashed with another source:\n", len(renamed))
+	for _, r := range renamed {
+		fmt.Fprintf(w, "- %s -> %s (%s)\n", r.RenamedFrom, r.Dest, r.Source)
+	}
+}
+
+// withMatchInfo copies how each source was found, and any rename it got to avoid a clash, from
+// the resolve results onto the results of applying the resolved plan, which names exact sources.
+func withMatchInfo(applied, resolved []executor.Result) []executor.Result {
+	bySource := make(map[string]executor.Result, len(resolved))
+	for _, r := range resolved {
+		if r.Source != "" {
+			bySource[absPath(r.Source)] = r
+		}
+	}
+	for i, r := range applied {
+		from, ok := bySource[absPath(r.Source)]
+		if !ok || r.Source == "" {
+			continue
+		}
+		applied[i].Strategy, applied[i].Confidence = from.Strategy, from.Confidence
+		applied[i].Candidates, applied[i].MoreCandidates = from.Candidates, from.MoreCandidates
+		if from.RenamedFrom != "" {
+			// the clash was resolved against the destination as planned, before cards moved it
+			rel, err := filepath.Rel(filepath.Dir(from.Dest), from.RenamedFrom)
+			if err == nil {
+				applied[i].RenamedFrom = filepath.Join(filepath.Dir(r.Dest), rel)
+			}
+		}
+	}
+	return applied
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// reportCounts separates files already in place from an earlier run (unchanged, per the output
+// manifest) from existing files that were left alone (skip).
+func reportCounts(w io.Writer, results []executor.Result) {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r.Action]++
+	}
+	fmt.Fprintf(w, "Summary: %d copy, %d write, %d unchanged, %d skip (exists, not written by an earlier run)\n",
+		counts["copy"], counts["write"], counts["unchanged"], counts["skip"])
+	if counts["delete"] > 0 || counts["rmdir"] > 0 {
+		fmt.Fprintf(w, "Pruned %d stale files and %d empty folders\n", counts["delete"], counts["rmdir"])
+	}
+}
+
+// pruneOutput removes files earlier runs wrote that are no longer planned. It only runs once
+// everything applied cleanly, so a failed copy never costs the previous one.
+func pruneOutput(ctx context.Context, opts *options, results []executor.Result, execOpts executor.Options, execErr error) ([]executor.Result, error) {
+	if !opts.prune || execErr != nil {
+		return results, execErr
+	}
+	pruned, err := executor.Prune(ctx, results, execOpts)
+	return append(results, pruned...), err
+}
+
+func reportPruneSkipped(w io.Writer, opts *options, execErr error) {
+	if opts.prune && isFailures(execErr) {
+		fmt.Fprintln(w, "Prune skipped because some files failed")
+	}
+}
+
+// continued reports whether a run may go on after err: there was no error, or only files that
+// failed in keep-going mode.
+func continued(err error) bool {
+	return err == nil || isFailures(err)
+}
+
+// reportable reports whether a run's results are worth printing despite err: the run
+// finished, possibly with keep-going failures, or was cancelled part way.
+func reportable(err error) bool {
+	return continued(err) || errors.Is(err, executor.ErrCancelled)
+}
+
+// reportCancelled tells the user the listed results are all a cancelled run got to.
+func reportCancelled(w io.Writer, err error) {
+	if errors.Is(err, executor.ErrCancelled) {
+		fmt.Fprintln(w, "Cancelled: the results above are what finished before the interrupt")
+	}
+}
+
+func isFailures(err error) bool {
+	var failed *executor.Failures
+	return errors.As(err, &failed)
+}
+
+// mergeFailures combines the failures of consecutive keep-going steps into one error over all
+// results; any other error from the later step is returned as is.
+func mergeFailures(prev, next error, results []executor.Result) error {
+	if next != nil && !isFailures(next) {
+		return next
+	}
+	if prev == nil && next == nil {
+		return nil
+	}
+	return executor.CollectFailures(results)
+}
+
+// reportFailures prints a table of the files that failed in keep-going mode by category,
+// followed by each failure.
+func reportFailures(w io.Writer, err error) {
+	var failed *executor.Failures
+	if !errors.As(err, &failed) {
+		return
+	}
+	fmt.Fprintf(w, "Failed %d files:\n", len(failed.Results))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CATEGORY\tFILES\tFIRST")
+	for _, g := range failed.Groups() {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", g.Category, len(g.Results), g.Results[0].Dest)
+	}
+	tw.Flush()
+	for _, r := range failed.Results {
+		fmt.Fprintf(w, "- %s: %v\n", r.Dest, r.Error)
+	}
+}
+
+// failureCounts counts keep-going failures by category for JSON output.
+func failureCounts(err error) map[string]int {
+	var failed *executor.Failures
+	if !errors.As(err, &failed) {
+		return nil
+	}
+	counts := make(map[string]int)
+	for _, g := range failed.Groups() {
+		counts[g.Category] = len(g.Results)
+	}
+	return counts
+}
+
+// reportUnsupported summarizes variants and files left out (or flagged) because the target cannot use them.
+func reportUnsupported(w io.Writer, excluded []layout.Exclusion, results []executor.Result, policy layout.UnsupportedPolicy) {
+	if len(excluded) > 0 {
+		verb := "Left out"
+		if policy == layout.UnsupportedWarn {
+			verb = "Warning: kept"
+		}
+		fmt.Fprintf(w, "%s %d variants the target cannot use:\n", verb, len(excluded))
+		for _, e := range excluded {
+			fmt.Fprintf(w, "- %s (%s): %s\n", e.Title, e.Content, e.Reason)
+		}
+	}
+
+	var files []executor.Result
+	for _, r := range results {
+		if r.Action == "unsupported" {
+			files = append(files, r)
+		}
+	}
+	if len(files) > 0 {
+		fmt.Fprintf(w, "Left out %d files with extensions the target cannot open:\n", len(files))
+		for _, r := range files {
+			fmt.Fprintf(w, "- %s\n", r.Source)
+		}
+	}
+}
+
 type jsonResult struct {
-	Source string `json:"source"`
-	Dest   string `json:"dest"`
-	Action string `json:"action"`
-	Error  string `json:"error,omitempty"`
+	Source         string               `json:"source"`
+	Dest           string               `json:"dest"`
+	Action         string               `json:"action"`
+	Error          string               `json:"error,omitempty"`
+	Strategy       string               `json:"strategy,omitempty"`
+	Confidence     float64              `json:"confidence,omitempty"`
+	Candidates     []executor.Candidate `json:"candidates,omitempty"`
+	MoreCandidates int                  `json:"moreCandidates,omitempty"`
+	Review         bool                 `json:"review,omitempty"`
+	RenamedFrom    string               `json:"renamedFrom,omitempty"`
 }
 
 func flattenResults(results []executor.Result) []jsonResult {
 	out := make([]jsonResult, 0, len(results))
 	for _, r := range results {
-		jr := jsonResult{Source: r.Source, Dest: r.Dest, Action: r.Action}
+		jr := jsonResult{
+			Source: r.Source, Dest: r.Dest, Action: r.Action,
+			Strategy: r.Strategy, Confidence: r.Confidence, Candidates: r.Candidates, MoreCandidates: r.MoreCandidates, Review: r.NeedsReview(),
+			RenamedFrom: r.RenamedFrom,
+		}
 		if r.Error != nil {
 			jr.Error = r.Error.Error()
 		}
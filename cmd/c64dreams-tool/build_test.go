@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/executor"
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestFinishJournalKeepsKeepGoingFailuresForRollback(t *testing.T) {
+	root := t.TempDir()
+	src, out := filepath.Join(root, "input"), filepath.Join(root, "output")
+	write := func(p, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(src, "alpha/alpha.d64"), "new")
+	write(filepath.Join(out, "a/alpha.d64"), "old")
+
+	planned := []layout.PlannedFile{
+		{GameID: "alpha", VariantID: "alpha", Source: "alpha/alpha.d64", Path: "a/alpha.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk, Resolved: true},
+		{GameID: "beta", VariantID: "beta", Source: "beta/beta.d64", Path: "b/beta.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk, Resolved: true},
+	}
+	journal, err := executor.StartJournal(out)
+	if err != nil {
+		t.Fatalf("StartJournal returned error: %v", err)
+	}
+	_, execErr := executor.Apply(context.Background(), planned, executor.Options{InputRoot: src, OutputRoot: out, Overwrite: true, KeepGoing: true, Journal: journal})
+	if !isFailures(execErr) {
+		t.Fatalf("expected keep-going failures, got %v", execErr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(out, "a/alpha.d64")); string(data) != "new" {
+		t.Fatalf("alpha.d64 should have been replaced, got %q", data)
+	}
+
+	err = finishJournal(journal, execErr, out)
+	var failed *executor.Failures
+	if !errors.As(err, &failed) {
+		t.Fatalf("finishJournal should keep the failures, got %v", err)
+	}
+	if _, err := executor.Rollback(out, false); err != nil {
+		t.Fatalf("Rollback returned error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(out, "a/alpha.d64")); string(data) != "old" {
+		t.Fatalf("rollback should restore the replaced file, got %q", data)
+	}
+}
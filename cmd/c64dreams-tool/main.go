@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
@@ -13,9 +16,18 @@ func main() {
 }
 
 func run() error {
+	// the first interrupt cancels the run so it can stop cleanly and report; a second one
+	// kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
 	cmd := newRootCmd()
 	cmd.SetOut(os.Stdout)
 	cmd.SetErr(os.Stderr)
 
-	return cmd.Execute()
+	return cmd.ExecuteContext(ctx)
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 
@@ -57,6 +58,7 @@ func newNormalizeCmd(opts *options) *cobra.Command {
 				}
 				fmt.Fprintf(cmd.OutOrStdout(), "- %s -> %s\n", ng.Title, ng.Name.Normalized)
 			}
+			reportMenuClashes(cmd.OutOrStdout(), normalized, normOpts.EffectiveDisplayLen())
 
 			return nil
 		},
@@ -64,3 +66,23 @@ func newNormalizeCmd(opts *options) *cobra.Command {
 
 	return cmd
 }
+
+// reportMenuClashes lists game names that looked identical within the target menu width and were suffixed.
+func reportMenuClashes(w io.Writer, games []model.NormalizedGame, displayLen int) {
+	if displayLen <= 0 {
+		return
+	}
+	var clashes []model.NormalizedGame
+	for _, ng := range games {
+		if ng.Name.DisplayCollision {
+			clashes = append(clashes, ng)
+		}
+	}
+	if len(clashes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%d names look the same in the first %d characters shown by the menu:\n", len(clashes), displayLen)
+	for _, ng := range clashes {
+		fmt.Fprintf(w, "- %s -> %s\n", ng.Title, ng.Name.Normalized)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wazp/c64dreams-tool/internal/cards"
+	"github.com/wazp/c64dreams-tool/internal/planfile"
+)
+
+func newPlanCmd(opts *options) *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Plan the layout and save it as a reviewable plan file without writing output",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOptions(opts); err != nil {
+				return err
+			}
+
+			if opts.sheet == "" {
+				return fmt.Errorf("--sheet is required")
+			}
+
+			if opts.input == "" {
+				return fmt.Errorf("--input is required")
+			}
+
+			run, err := planLayout(cmd, opts)
+			if err != nil {
+				return err
+			}
+
+			// in keep-going mode the plan holds the files that resolved; failures are reported after writing it
+			resolved, results, resolveErr := resolvePlan(cmd.Context(), opts, run.planned)
+			if !continued(resolveErr) {
+				return resolveErr
+			}
+			var cardList []cards.Card
+			if opts.cardSize != "" {
+				if resolved, cardList, err = packCards(opts, resolved); err != nil {
+					return err
+				}
+			}
+
+			checksum, err := planfile.SheetChecksum(opts.sheet)
+			if err != nil {
+				return err
+			}
+			pf := planfile.New(opts.target, checksum, resolved)
+
+			if out == "" {
+				if err := planfile.Encode(cmd.OutOrStdout(), pf); err != nil {
+					return err
+				}
+				return resolveErr
+			}
+			if err := planfile.Write(out, pf); err != nil {
+				return err
+			}
+
+			var size int64
+			for _, f := range resolved {
+				size += f.Size
+			}
+			reportMenuClashes(cmd.OutOrStdout(), run.normalized, run.displayLen)
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote plan for %s with %d files (%d bytes) to %s\n", opts.target, len(resolved), size, out)
+			reportUnsupported(cmd.OutOrStdout(), run.excluded, results, run.layout.Unsupported)
+			reportCards(cmd.OutOrStdout(), cardList)
+			reportReview(cmd.OutOrStdout(), results)
+			reportRenamed(cmd.OutOrStdout(), results)
+			reportFailures(cmd.OutOrStdout(), resolveErr)
+			return resolveErr
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "Write the plan to this file instead of stdout")
+	cmd.AddCommand(newPlanDiffCmd(opts))
+
+	return cmd
+}
+
+func newPlanDiffCmd(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <old.json> <new.json>",
+		Short: "Compare two plan files: added, removed, moved and changed-source files",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := validateOptions(opts); err != nil {
+				return err
+			}
+
+			older, err := planfile.Read(args[0])
+			if err != nil {
+				return err
+			}
+			newer, err := planfile.Read(args[1])
+			if err != nil {
+				return err
+			}
+			diff := planfile.Compare(older, newer)
+
+			if opts.json {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(diff)
+			}
+
+			w := cmd.OutOrStdout()
+			if older.SheetSHA256 != newer.SheetSHA256 {
+				fmt.Fprintln(w, "Sheet changed between plans")
+			}
+			if diff.Empty() {
+				fmt.Fprintln(w, "No changes")
+				return nil
+			}
+			for _, f := range diff.Added {
+				fmt.Fprintf(w, "+ %s\n", f.Path)
+			}
+			for _, f := range diff.Removed {
+				fmt.Fprintf(w, "- %s\n", f.Path)
+			}
+			for _, m := range diff.Moved {
+				fmt.Fprintf(w, "> %s -> %s\n", m.From.Path, m.To.Path)
+			}
+			for _, m := range diff.Changed {
+				switch {
+				case m.From.Source != m.To.Source:
+					fmt.Fprintf(w, "~ %s (source %s -> %s)\n", m.To.Path, m.From.Source, m.To.Source)
+				case m.From.Size != m.To.Size:
+					fmt.Fprintf(w, "~ %s (size %d -> %d)\n", m.To.Path, m.From.Size, m.To.Size)
+				default:
+					fmt.Fprintf(w, "~ %s (content)\n", m.To.Path)
+				}
+			}
+			fmt.Fprintf(w, "%d added, %d removed, %d moved, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Moved), len(diff.Changed))
+			return nil
+		},
+	}
+
+	return cmd
+}
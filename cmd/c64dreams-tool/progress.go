@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/wazp/c64dreams-tool/internal/executor"
+)
+
+// progressInterval limits how often the terminal progress line is redrawn.
+const progressInterval = 100 * time.Millisecond
+
+// progressReporter returns the executor progress callback for the output mode, and a function
+// that ends the display before results are printed. With --json every event is written to
+// stderr as one JSON line, so stdout stays a single JSON document; on a terminal a live line
+// is drawn on stdout; otherwise nothing is reported.
+func progressReporter(cmd *cobra.Command, opts *options) (func(executor.Event), func()) {
+	if opts.json {
+		enc := json.NewEncoder(cmd.ErrOrStderr())
+		return func(ev executor.Event) { enc.Encode(ev) }, func() {}
+	}
+	out := cmd.OutOrStdout()
+	if !isTerminal(out) {
+		return nil, func() {}
+	}
+	line := &progressLine{w: out}
+	return line.update, line.clear
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progressLine redraws a single terminal line with files and bytes done, throughput and ETA.
+type progressLine struct {
+	w       io.Writer
+	start   time.Time
+	drawn   time.Time
+	visible bool
+}
+
+func (l *progressLine) update(ev executor.Event) {
+	now := time.Now()
+	if ev.Kind == executor.EventStarted {
+		l.start = now
+	}
+	if ev.Kind != executor.EventFinished && now.Sub(l.drawn) < progressInterval {
+		return
+	}
+	l.drawn = now
+	fmt.Fprintf(l.w, "\r\033[K%s", progressText(ev, now.Sub(l.start)))
+	l.visible = true
+}
+
+func (l *progressLine) clear() {
+	if l.visible {
+		fmt.Fprint(l.w, "\r\033[K")
+		l.visible = false
+	}
+}
+
+// progressText formats an event for the progress line. The ETA follows bytes when the plan
+// knows its sizes and files otherwise.
+func progressText(ev executor.Event, elapsed time.Duration) string {
+	text := fmt.Sprintf("%d/%d files  %s", ev.FilesDone, ev.FilesTotal, formatSize(ev.BytesDone))
+	if ev.BytesTotal > 0 {
+		text += " of " + formatSize(ev.BytesTotal)
+	}
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		return text
+	}
+	text += fmt.Sprintf("  %s/s", formatSize(int64(float64(ev.BytesDone)/secs)))
+
+	var remaining float64
+	switch {
+	case ev.BytesTotal > 0 && ev.BytesDone > 0:
+		remaining = secs * float64(ev.BytesTotal-ev.BytesDone) / float64(ev.BytesDone)
+	case ev.FilesDone > 0:
+		remaining = secs * float64(ev.FilesTotal-ev.FilesDone) / float64(ev.FilesDone)
+	default:
+		return text
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return text + "  ETA " + (time.Duration(remaining) * time.Second).String()
+}
+
+// formatSize prints a byte count in the decimal units --card-size accepts.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1000*1000*1000:
+		return fmt.Sprintf("%.1f GB", float64(n)/1e9)
+	case n >= 1000*1000:
+		return fmt.Sprintf("%.1f MB", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%.1f KB", float64(n)/1e3)
+	}
+	return fmt.Sprintf("%d B", n)
+}
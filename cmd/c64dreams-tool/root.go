@@ -2,36 +2,56 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/wazp/c64dreams-tool/internal/cards"
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/internal/profiles"
 	"github.com/wazp/c64dreams-tool/pkg/model"
 )
 
 type options struct {
-	input      string
-	output     string
-	sheet      string
-	target     model.TargetDevice
-	maxNameLen int
-	region     string
-	groupBy    string
-	dryRun     bool
-	overwrite  bool
-	groupMedia bool
-	groupAlpha bool
-	alphaSize  int
-	json       bool
+	input       string
+	output      string
+	sheet       string
+	profiles    string
+	target      model.TargetDevice
+	maxNameLen  int
+	region      string
+	groupBy     []string
+	dryRun      bool
+	overwrite   bool
+	groupMedia  bool
+	groupAlpha  bool
+	alphaSize   int
+	alphaDepth  int
+	screenSize  int
+	flat        bool
+	mediaDirs   map[string]string
+	cardSize    string
+	strict      bool
+	keepGoing   bool
+	jobs        int
+	prune       bool
+	clusterSize int64
+	template    string
+	maxEntries  int
+	unsupported string
+	json        bool
 }
 
 func newRootCmd() *cobra.Command {
 	opts := &options{
-		target:    model.TargetSD2IEC,
-		region:    "both",
-		groupBy:   "letter",
-		dryRun:    true,
-		alphaSize: 1,
+		target:      model.TargetSD2IEC,
+		region:      "both",
+		dryRun:      true,
+		alphaSize:   1,
+		jobs:        1,
+		clusterSize: cards.DefaultClusterSize,
+		unsupported: string(layout.UnsupportedConvert),
 	}
 
 	cmd := &cobra.Command{
@@ -44,29 +64,49 @@ func newRootCmd() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&opts.input, "input", "", "Path to the C64 Dreams directory")
 	cmd.PersistentFlags().StringVar(&opts.output, "output", "", "Destination path for generated files")
 	cmd.PersistentFlags().StringVar(&opts.sheet, "sheet", "", "Path to the spreadsheet CSV with metadata")
-	cmd.PersistentFlags().StringVar((*string)(&opts.target), "target", string(opts.target), "Target device: sd2iec, pi1541, kungfuflash, or ultimate")
+	cmd.PersistentFlags().StringVar((*string)(&opts.target), "target", string(opts.target), "Target device: sd2iec, pi1541, kungfuflash, ultimate, thec64, mister, vice, or a profile from --profiles")
+	cmd.PersistentFlags().StringVar(&opts.profiles, "profiles", "", "Path to a JSON file with additional target profiles")
 	cmd.PersistentFlags().IntVar(&opts.maxNameLen, "max-name-len", 0, "Maximum filename length; uses target profile when zero")
 	cmd.PersistentFlags().StringVar(&opts.region, "region", opts.region, "Region filter: pal, ntsc, or both")
-	cmd.PersistentFlags().StringVar(&opts.groupBy, "group-by", opts.groupBy, "Grouping strategy: letter or none")
+	cmd.PersistentFlags().StringSliceVar(&opts.groupBy, "group-by", nil, "Folder levels in order: letter, media, genre, year, publisher, joystick-port, players, or none (overrides --group-media/--group-alpha)")
 	cmd.PersistentFlags().BoolVar(&opts.dryRun, "dry-run", opts.dryRun, "Preview actions without writing files")
 	cmd.PersistentFlags().BoolVar(&opts.overwrite, "overwrite", false, "Allow overwriting existing files when applying layout")
 	cmd.PersistentFlags().BoolVar(&opts.groupMedia, "group-media", false, "Group output by media type (disks/tape/cart)")
+	cmd.PersistentFlags().StringToStringVar(&opts.mediaDirs, "media-dir", nil, "Rename media group folders, e.g. disks=DISKS,prg=carts,cart=carts (groups sharing a name are merged)")
 	cmd.PersistentFlags().BoolVar(&opts.groupAlpha, "group-alpha", false, "Group output alphabetically")
 	cmd.PersistentFlags().IntVar(&opts.alphaSize, "alpha-bucket-size", opts.alphaSize, "Alphabetical bucket size when grouping")
+	cmd.PersistentFlags().IntVar(&opts.alphaDepth, "alpha-depth", 0, "Nest alphabetical folders up to this many prefix levels (a/ab/abc), sized so each folder fits one screen")
+	cmd.PersistentFlags().IntVar(&opts.screenSize, "screen-entries", 0, "Entries per folder that --alpha-depth nests for; 22 (one screen) when zero")
+	cmd.PersistentFlags().BoolVar(&opts.flat, "flat", false, "Place single-file games directly in their bucket; only games with several files get a folder")
+	cmd.PersistentFlags().IntVar(&opts.maxEntries, "max-entries", 0, "Maximum game entries per directory before splitting into name ranges; uses target profile when zero")
+	cmd.PersistentFlags().StringVar(&opts.template, "path-template", "", "Output path template, e.g. {media}/{alpha:2}/{name}/{label}.{ext} (replaces grouping)")
+	cmd.PersistentFlags().StringVar(&opts.cardSize, "card-size", "", "Split output across card1/, card2/, ... of this capacity, e.g. 1900M or 2G (decimal units)")
+	cmd.PersistentFlags().Int64Var(&opts.clusterSize, "cluster-size", opts.clusterSize, "Card filesystem cluster size in bytes; file sizes are rounded up to it when packing cards")
+	cmd.PersistentFlags().StringVar(&opts.unsupported, "unsupported", opts.unsupported, "Media the target cannot use: convert (extract zips, skip the rest), skip or warn")
+	cmd.PersistentFlags().BoolVar(&opts.strict, "strict", false, "Fail when a source is only found by an ambiguous or low-confidence fuzzy match")
+	cmd.PersistentFlags().BoolVar(&opts.keepGoing, "keep-going", false, "Carry on after a file fails, then report every failure and exit non-zero")
+	cmd.PersistentFlags().BoolVar(&opts.prune, "prune", false, "After applying, delete files earlier runs wrote that are no longer in the plan, and empty folders")
+	cmd.PersistentFlags().IntVar(&opts.jobs, "jobs", opts.jobs, "Number of files copied concurrently")
 	cmd.PersistentFlags().BoolVar(&opts.json, "json", false, "Emit JSON output for automation")
 
 	cmd.AddCommand(newNormalizeCmd(opts))
 	cmd.AddCommand(newScanCmd(opts))
 	cmd.AddCommand(newIngestCmd(opts))
 	cmd.AddCommand(newBuildCmd(opts))
+	cmd.AddCommand(newPlanCmd(opts))
+	cmd.AddCommand(newApplyCmd(opts))
 
 	return cmd
 }
 
 func validateOptions(opts *options) error {
-	switch opts.target {
-	case model.TargetSD2IEC, model.TargetPi1541, model.TargetKungFuFlash, model.TargetUltimate:
-	default:
+	if opts.profiles != "" {
+		if err := profiles.LoadAndRegister(opts.profiles); err != nil {
+			return err
+		}
+	}
+
+	if !model.KnownTarget(opts.target) {
 		return fmt.Errorf("invalid target %q (expected one of: %s)", opts.target, strings.Join(allowedTargets(), ", "))
 	}
 
@@ -76,28 +116,100 @@ func validateOptions(opts *options) error {
 		return fmt.Errorf("invalid region %q (expected pal, ntsc, or both)", opts.region)
 	}
 
-	switch opts.groupBy {
-	case "letter", "none":
+	if _, err := layout.ParseGroupings(opts.groupBy); err != nil {
+		return fmt.Errorf("invalid group-by: %w (expected none or a list of: %s)", err, strings.Join(groupingNames(), ", "))
+	}
+
+	if err := layout.ValidateMediaDirs(opts.mediaDirs); err != nil {
+		return fmt.Errorf("invalid media-dir: %w", err)
+	}
+
+	if opts.template != "" {
+		if _, err := layout.ParseTemplate(opts.template); err != nil {
+			return err
+		}
+	}
+
+	switch layout.UnsupportedPolicy(opts.unsupported) {
+	case layout.UnsupportedConvert, layout.UnsupportedSkip, layout.UnsupportedWarn:
 	default:
-		return fmt.Errorf("invalid group-by %q (expected letter or none)", opts.groupBy)
+		return fmt.Errorf("invalid unsupported %q (expected convert, skip or warn)", opts.unsupported)
 	}
 
 	if opts.maxNameLen < 0 {
 		return fmt.Errorf("max-name-len must be zero or positive")
 	}
 
+	if opts.maxEntries < 0 {
+		return fmt.Errorf("max-entries must be zero or positive")
+	}
+
 	if opts.alphaSize < 0 {
 		return fmt.Errorf("alpha-bucket-size must be zero or positive")
 	}
 
+	if _, err := parseSize(opts.cardSize); err != nil {
+		return fmt.Errorf("invalid card-size: %w", err)
+	}
+
+	if opts.clusterSize <= 0 {
+		return fmt.Errorf("cluster-size must be positive")
+	}
+
+	if opts.alphaDepth < 0 {
+		return fmt.Errorf("alpha-depth must be zero or positive")
+	}
+	if opts.alphaDepth > 1 && opts.template != "" {
+		return fmt.Errorf("alpha-depth cannot be combined with path-template; use {alpha:N} in the template instead")
+	}
+
+	if opts.screenSize < 0 {
+		return fmt.Errorf("screen-entries must be zero or positive")
+	}
+
+	if opts.jobs < 1 {
+		return fmt.Errorf("jobs must be at least 1")
+	}
+
 	return nil
 }
 
+func groupingNames() []string {
+	var names []string
+	for _, g := range layout.Groupings() {
+		names = append(names, string(g))
+	}
+	return names
+}
+
 func allowedTargets() []string {
-	return []string{
-		string(model.TargetSD2IEC),
-		string(model.TargetPi1541),
-		string(model.TargetKungFuFlash),
-		string(model.TargetUltimate),
+	targets := model.Targets()
+	out := make([]string, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, string(t))
+	}
+	return out
+}
+
+// parseSize reads a byte count with an optional decimal K, M or G suffix. Empty means zero.
+func parseSize(raw string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(raw))
+	if s == "" {
+		return 0, nil
+	}
+	mult := int64(1)
+	s = strings.TrimSuffix(s, "B")
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1000, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1000*1000, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1000*1000*1000, strings.TrimSuffix(s, "G")
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive size", raw)
 	}
+	return int64(n * float64(mult)), nil
 }
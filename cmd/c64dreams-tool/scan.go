@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/fs"
@@ -24,7 +25,7 @@ func newScanCmd(opts *options) *cobra.Command {
 				return fmt.Errorf("--input is required")
 			}
 
-			files, err := scanC64Files(opts.input)
+			files, err := scanC64Files(cmd.Context(), opts.input)
 			if err != nil {
 				return err
 			}
@@ -61,7 +62,7 @@ type scannedFile struct {
 	Ext  string `json:"ext"`
 }
 
-func scanC64Files(root string) ([]scannedFile, error) {
+func scanC64Files(ctx context.Context, root string) ([]scannedFile, error) {
 	allowed := allC64Exts()
 	rootAbs, err := filepath.Abs(root)
 	if err != nil {
@@ -73,6 +74,9 @@ func scanC64Files(root string) ([]scannedFile, error) {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if d.IsDir() {
 			return nil
 		}
@@ -0,0 +1,176 @@
+package cards
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+// DefaultClusterSize is the FAT16 cluster size of a 2 GB card; every file occupies whole clusters.
+const DefaultClusterSize = 32 * 1024
+
+// IndexName is the file listing which card holds which title, written at the output root.
+const IndexName = "cards.tsv"
+
+// Card is one output root holding a contiguous range of games.
+type Card struct {
+	Name  string
+	Used  int64 // Bytes on disk after cluster rounding
+	Games []Game
+}
+
+// Game is a title placed on a card.
+type Game struct {
+	ID    string
+	Title string
+	Path  string // First file of the game on the card, without the card prefix
+}
+
+type unit struct {
+	game  Game
+	files []int
+	size  int64
+	key   []string
+}
+
+// Split packs a resolved plan onto cards of the given capacity. Games stay whole and keep their
+// path order, so each card covers a contiguous range. When a card fills up it is closed at the
+// highest-level folder boundary (e.g. between letters rather than inside one) that still leaves
+// it at least half full. Returned files have their paths prefixed with "card1/", "card2/", ...
+func Split(files []layout.PlannedFile, capacity, clusterSize int64) ([]layout.PlannedFile, []Card, error) {
+	if capacity <= 0 {
+		return nil, nil, fmt.Errorf("card size must be positive")
+	}
+	if clusterSize <= 0 {
+		clusterSize = DefaultClusterSize
+	}
+
+	units := gameUnits(files, clusterSize)
+	for _, u := range units {
+		if u.size > capacity {
+			return nil, nil, fmt.Errorf("%s needs %d bytes, more than a card holds (%d)", u.game.Title, u.size, capacity)
+		}
+	}
+
+	var cards []Card
+	out := make([]layout.PlannedFile, 0, len(files))
+	for start := 0; start < len(units); {
+		end := cardEnd(units, start, capacity)
+		card := Card{Name: fmt.Sprintf("card%d", len(cards)+1)}
+		for _, u := range units[start:end] {
+			card.Used += u.size
+			card.Games = append(card.Games, u.game)
+			for _, i := range u.files {
+				f := files[i]
+				f.Path = path.Join(card.Name, f.Path)
+				if f.GameDir != "" {
+					f.GameDir = path.Join(card.Name, f.GameDir)
+				}
+				out = append(out, f)
+			}
+		}
+		cards = append(cards, card)
+		start = end
+	}
+	return out, cards, nil
+}
+
+// cardEnd returns the index after the last unit that goes on the card starting at start.
+func cardEnd(units []unit, start int, capacity int64) int {
+	var used int64
+	end := start
+	for end < len(units) && used+units[end].size <= capacity {
+		used += units[end].size
+		end++
+	}
+	if end == len(units) {
+		return end
+	}
+
+	// close the card at the shallowest folder boundary among the breaks that keep it half full,
+	// preferring the fullest card on ties
+	best, bestDepth := end, sharedDepth(units[end-1].key, units[end].key)
+	earlier, earlierDepth := -1, bestDepth
+	used = 0
+	for i := start; i < end-1; i++ {
+		used += units[i].size
+		if used*2 < capacity {
+			continue
+		}
+		if depth := sharedDepth(units[i].key, units[i+1].key); depth <= earlierDepth || earlier < 0 {
+			earlier, earlierDepth = i+1, depth
+		}
+	}
+	if earlier >= 0 && earlierDepth < bestDepth {
+		return earlier
+	}
+	return best
+}
+
+// gameUnits groups files by game in path order, with cluster-rounded sizes.
+func gameUnits(files []layout.PlannedFile, clusterSize int64) []unit {
+	byGame := make(map[string]*unit)
+	var order []*unit
+	for i, f := range files {
+		u, ok := byGame[f.GameID]
+		if !ok {
+			u = &unit{game: Game{ID: f.GameID, Title: f.Title}}
+			byGame[f.GameID] = u
+			order = append(order, u)
+		}
+		u.files = append(u.files, i)
+		size := f.Size
+		if f.Body != "" {
+			size = int64(len(f.Body))
+		}
+		u.size += (size + clusterSize - 1) / clusterSize * clusterSize
+		// launchers live elsewhere (e.g. MiSTer's _C64 menu); the game is ordered by its own files
+		if f.Body == "" && (u.game.Path == "" || f.Path < u.game.Path) {
+			u.game.Path = f.Path
+		}
+	}
+
+	units := make([]unit, 0, len(order))
+	for _, u := range order {
+		if u.game.Path == "" {
+			u.game.Path = files[u.files[0]].Path
+		}
+		u.key = strings.Split(path.Dir(u.game.Path), "/")
+		units = append(units, *u)
+	}
+	sort.SliceStable(units, func(i, j int) bool {
+		return strings.ToLower(units[i].game.Path) < strings.ToLower(units[j].game.Path)
+	})
+	return units
+}
+
+// sharedDepth counts the leading folders two game folders have in common.
+func sharedDepth(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && strings.EqualFold(a[n], b[n]) {
+		n++
+	}
+	return n
+}
+
+// Index renders a tab-separated list of which card holds which title, sorted by title.
+func Index(cards []Card) string {
+	type row struct{ card, title, path string }
+	var rows []row
+	for _, c := range cards {
+		for _, g := range c.Games {
+			rows = append(rows, row{c.Name, g.Title, path.Join(c.Name, g.Path)})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return strings.ToLower(rows[i].title) < strings.ToLower(rows[j].title) })
+
+	var b strings.Builder
+	b.WriteString("card\ttitle\tpath\n")
+	for _, r := range rows {
+		fmt.Fprintf(&b, "%s\t%s\t%s\n", r.card, r.title, r.path)
+	}
+	return b.String()
+}
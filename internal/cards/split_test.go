@@ -0,0 +1,79 @@
+package cards
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+func TestSplitBreaksAtBucketBoundary(t *testing.T) {
+	files := []layout.PlannedFile{
+		{GameID: "bb", Title: "Bb", Path: "b/bb/bb.d64", Size: 30},
+		{GameID: "aa", Title: "Aa", Path: "a/aa/aa.d64", Size: 30},
+		{GameID: "ab", Title: "Ab", Path: "a/ab/ab.d64", Size: 30},
+		{GameID: "ba", Title: "Ba", Path: "b/ba/ba.d64", Size: 15},
+		{GameID: "ba", Title: "Ba", Path: "b/ba/ba2.d64", Size: 5},
+	}
+
+	out, cards, err := Split(files, 100, 1)
+	if err != nil {
+		t.Fatalf("Split returned error: %v", err)
+	}
+
+	// a greedy fill would put "ba" on card1; the letter boundary wins because card1 stays half full
+	if len(cards) != 2 {
+		t.Fatalf("expected 2 cards, got %d", len(cards))
+	}
+	if got := titles(cards[0]); got != "Aa,Ab" {
+		t.Fatalf("card1 holds %s", got)
+	}
+	if got := titles(cards[1]); got != "Ba,Bb" {
+		t.Fatalf("card2 holds %s", got)
+	}
+	if cards[1].Used != 50 {
+		t.Fatalf("card2 uses %d bytes", cards[1].Used)
+	}
+
+	paths := map[string]bool{}
+	for _, f := range out {
+		paths[f.Path] = true
+	}
+	for _, p := range []string{"card1/a/aa/aa.d64", "card2/b/ba/ba.d64", "card2/b/ba/ba2.d64", "card2/b/bb/bb.d64"} {
+		if !paths[p] {
+			t.Fatalf("missing %s in %v", p, paths)
+		}
+	}
+
+	index := Index(cards)
+	if !strings.Contains(index, "card2\tBa\tcard2/b/ba/ba.d64\n") {
+		t.Fatalf("unexpected index:\n%s", index)
+	}
+}
+
+func TestSplitRoundsToClustersAndRejectsOversizedGames(t *testing.T) {
+	files := []layout.PlannedFile{
+		{GameID: "a", Title: "A", Path: "a/a.prg", Size: 1},
+		{GameID: "b", Title: "B", Path: "b/b.prg", Size: 1},
+	}
+	// each one-byte file occupies a whole 4 KB cluster
+	_, cards, err := Split(files, 4096, 4096)
+	if err != nil {
+		t.Fatalf("Split returned error: %v", err)
+	}
+	if len(cards) != 2 {
+		t.Fatalf("expected 2 cards, got %d", len(cards))
+	}
+
+	if _, _, err := Split(files, 100, 4096); err == nil {
+		t.Fatalf("expected error for a game larger than a card")
+	}
+}
+
+func titles(c Card) string {
+	var out []string
+	for _, g := range c.Games {
+		out = append(out, g.Title)
+	}
+	return strings.Join(out, ",")
+}
@@ -1,6 +1,9 @@
 package executor
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -9,6 +12,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/wazp/c64dreams-tool/internal/layout"
 	"github.com/wazp/c64dreams-tool/pkg/model"
@@ -16,16 +20,40 @@ import (
 
 // Result captures the outcome of applying a single planned file.
 type Result struct {
-	Source string
-	Dest   string
-	Action string // copy, skip, mkdir, error
-	Error  error
+	Source    string
+	Dest      string
+	Action    string // copy, write, unchanged, skip, mkdir, unsupported, error, cancelled
+	Error     error
+	VariantID string // Planned variant this result belongs to
+
+	// RenamedFrom is the destination this file would have had if another source had not
+	// already claimed it in the same run; empty otherwise
+	RenamedFrom string
+
+	Strategy       string      // How the planned source was found (StrategyExact, StrategySlugDir, ...); empty for generated files
+	Confidence     float64     // Score of the chosen source, from 0 to 1
+	Candidates     []Candidate // Best scored sources considered, best first (at most five); set when the source was not at its literal path
+	MoreCandidates int         // Sources considered that scored below those in Candidates
 }
 
-// Apply executes a planned layout onto the filesystem with safety and dry-run support.
-func Apply(planned []layout.PlannedFile, opts Options) ([]Result, error) {
-	results := make([]Result, 0, len(planned))
+// NeedsReview reports whether the source behind this result was a weak or ambiguous match.
+func (r Result) NeedsReview() bool {
+	if r.Strategy == "" || r.Strategy == StrategyExact {
+		return false
+	}
+	m := sourceMatch{strategy: r.Strategy, candidates: r.Candidates}
+	return m.ambiguous() || r.Confidence < ReviewConfidence
+}
 
+// Apply executes a planned layout onto the filesystem with safety and dry-run support.
+// Every destination, including expanded directories and siblings, is decided before anything
+// is written, so files that would land on the same path are caught up front (see
+// resolveClashes).
+// Files written are recorded in the output root's manifest; files it shows are already in
+// place from an earlier run are reported as "unchanged" instead of being copied again.
+// Cancelling ctx stops the run between files and in the middle of a copy; the partial file is
+// removed and the results so far are returned with an error wrapping ErrCancelled.
+func Apply(ctx context.Context, planned []layout.PlannedFile, opts Options) ([]Result, error) {
 	if opts.OutputRoot == "" {
 		return nil, errors.New("output root is required")
 	}
@@ -43,308 +71,437 @@ func Apply(planned []layout.PlannedFile, opts Options) ([]Result, error) {
 		return nil, fmt.Errorf("resolve output root: %w", err)
 	}
 
-	for _, p := range sorted {
-		cleanRel := path.Clean(p.Path)
-		if path.IsAbs(cleanRel) {
-			res := Result{Dest: cleanRel, Action: "error", Error: errors.New("destination path must be relative")}
-			results = append(results, res)
-			return results, res.Error
+	inputAbs, err := filepath.Abs(opts.InputRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve input root: %w", err)
+	}
+	man := &manifestState{m: &Manifest{Version: manifestVersion, Files: map[string]ManifestEntry{}}, inputAbs: inputAbs, outputAbs: outputAbs, journal: opts.Journal}
+	if !opts.VerifyOnly {
+		if man.m, err = ReadManifest(outputAbs); err != nil {
+			return nil, err
 		}
+	}
 
-		destFull := filepath.Join(outputAbs, filepath.FromSlash(cleanRel))
-		destFull = filepath.Clean(destFull)
+	var totalBytes int64
+	for _, p := range sorted {
+		totalBytes += p.Size
+	}
+	prog := newProgress(opts.Progress, len(sorted), totalBytes)
+	prog.emit(EventStarted, "", "")
 
-		if !strings.HasPrefix(destFull, outputAbs) {
-			res := Result{Dest: destFull, Action: "error", Error: errors.New("destination escapes output root")}
-			results = append(results, res)
-			return results, res.Error
+	idx := newSourceIndex(ctx, opts.InputRoot)
+	plans := make([]plannedOps, len(sorted))
+	for i, p := range sorted {
+		if ctx.Err() != nil {
+			prog.emit(EventFinished, "", "")
+			return nil, cancelled(ctx)
 		}
+		plans[i] = planFile(ctx, p, outputAbs, opts, idx)
+	}
+	dropPlannedSiblings(plans)
+	resolveClashes(plans)
 
-		srcRel := p.Source
-		if srcRel == "" {
-			srcRel = cleanRel
-		}
-		srcRelClean := path.Clean(srcRel)
-		if path.IsAbs(srcRelClean) {
-			res := Result{Dest: destFull, Source: srcRelClean, Action: "error", Error: errors.New("source path must be relative")}
-			results = append(results, res)
-			return results, res.Error
+	apply := func(i int) ([]Result, error) {
+		p := sorted[i]
+		prog.emit(EventFileStarted, p.Path, "")
+		fileResults, err := applyPlanned(ctx, p, plans[i], dry, opts, man, prog)
+		for k := range fileResults {
+			fileResults[k].VariantID = p.VariantID
 		}
-		srcFull := filepath.Join(opts.InputRoot, filepath.FromSlash(srcRelClean))
+		prog.finished(p.Path, plannedAction(fileResults), p.Size)
+		return fileResults, err
+	}
 
-		dir := filepath.Dir(destFull)
-		if !dry {
-			if err := os.MkdirAll(dir, 0o755); err != nil {
-				res := Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("mkdir: %w", err)}
-				results = append(results, res)
-				return results, res.Error
-			}
-		} else {
-			results = append(results, Result{Source: srcFull, Dest: dir, Action: "mkdir"})
+	var results []Result
+	if opts.Jobs > 1 {
+		results, err = applyParallel(ctx, destinationGroups(plans), len(sorted), opts, apply)
+	} else {
+		results, err = applySequential(ctx, len(sorted), opts, apply)
+	}
+	if !dry {
+		// record what was written even when the run failed part way
+		if saveErr := man.save(); saveErr != nil {
+			err = errors.Join(err, saveErr)
 		}
+	}
+	prog.emit(EventFinished, "", "")
+	return results, err
+}
 
-		allowedExts := aliasesForExt(p.Content, strings.TrimPrefix(strings.ToLower(filepath.Ext(cleanRel)), "."))
+// plannedAction sums up a planned file's results for progress events: the failing action if
+// one failed, otherwise the first action that is not a mkdir.
+func plannedAction(results []Result) string {
+	action := ""
+	for _, r := range results {
+		switch {
+		case r.Action == "error" || r.Action == "cancelled":
+			return r.Action
+		case action == "" && r.Action != "mkdir":
+			action = r.Action
+		}
+	}
+	return action
+}
 
-		srcInfo, err := os.Stat(srcFull)
-		if err != nil {
-			base := filepath.Base(srcRelClean)
-			dirSlug := slug(filepath.Base(filepath.Dir(cleanRel)))
-			titleSlug := slug(p.Title)
-			fileSlug := slug(strings.TrimSuffix(base, filepath.Ext(base)))
-			plannedSlug := slug(strings.TrimSuffix(filepath.Base(cleanRel), filepath.Ext(cleanRel)))
-
-			match, matchErr := findBySlug(opts.InputRoot, []string{dirSlug, titleSlug}, []string{fileSlug, plannedSlug}, allowedExts)
-			if matchErr != nil {
-				match, matchErr = findInMatchingDir(opts.InputRoot, filepath.Base(filepath.Dir(cleanRel)), allowedExts)
-			}
-			if matchErr != nil {
-				match, matchErr = findCaseInsensitive(opts.InputRoot, base)
-			}
-			if matchErr != nil {
-				match, matchErr = findCaseInsensitiveNoExt(opts.InputRoot, base)
-			}
-			if matchErr != nil {
-				match, matchErr = findAnyBySlug(opts.InputRoot, []string{titleSlug, fileSlug, plannedSlug}, allowedExts)
-			}
-			if matchErr != nil {
-				match, matchErr = findAnyByExt(opts.InputRoot, allowedExts)
-			}
-			if matchErr != nil {
-				res := Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("source missing: %w", err)}
-				results = append(results, res)
-				return results, res.Error
-			}
-			srcFull = match
-			srcInfo, err = os.Stat(srcFull)
-			if err != nil {
-				res := Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("source missing after search: %w", err)}
-				results = append(results, res)
-				return results, res.Error
-			}
+// applySequential applies n sorted planned files one at a time.
+func applySequential(ctx context.Context, n int, opts Options, apply func(int) ([]Result, error)) ([]Result, error) {
+	results := make([]Result, 0, n)
+	for i := 0; i < n; i++ {
+		if ctx.Err() != nil {
+			return results, cancelled(ctx)
 		}
-		if srcInfo.IsDir() {
-			files, pickErr := pickFilesInDir(srcFull, allC64Exts())
-			if pickErr != nil {
-				res := Result{Source: srcFull, Dest: destFull, Action: "error", Error: pickErr}
-				results = append(results, res)
-				return results, res.Error
-			}
-			destDir := filepath.Dir(destFull)
-			for _, f := range files {
-				fileName := sanitizeFileName(filepath.Base(f))
-				destPath := filepath.Join(destDir, fileName)
-				if opts.VerifyOnly {
-					results = append(results, Result{Source: f, Dest: destPath, Action: "skip"})
-					continue
-				}
-				if dry {
-					results = append(results, Result{Source: f, Dest: destPath, Action: "copy"})
-					continue
-				}
-				if err := copyFile(f, destPath); err != nil {
-					res := Result{Source: f, Dest: destPath, Action: "error", Error: err}
-					results = append(results, res)
-					return results, res.Error
-				}
-				results = append(results, Result{Source: f, Dest: destPath, Action: "copy"})
-			}
-			continue
+		fileResults, err := apply(i)
+		if errors.Is(err, ErrCancelled) {
+			return append(results, fileResults...), err
 		}
-
-		// include siblings in the same directory that match C64 extensions
-		dirFiles, _ := pickFilesInDir(filepath.Dir(srcFull), allC64Exts())
-		fileSet := make(map[string]struct{})
-		var toCopy []string
-		for _, f := range append([]string{srcFull}, dirFiles...) {
-			if _, ok := fileSet[f]; ok {
-				continue
-			}
-			fileSet[f] = struct{}{}
-			toCopy = append(toCopy, f)
+		results = append(results, fileResults...)
+		if err != nil && !opts.KeepGoing {
+			return results, err
 		}
+	}
 
-		destDir := filepath.Dir(destFull)
-		for _, f := range toCopy {
-			fileName := sanitizeFileName(filepath.Base(f))
-			destPath := filepath.Join(destDir, fileName)
+	if opts.KeepGoing {
+		return results, CollectFailures(results)
+	}
+	return results, nil
+}
 
-			if opts.VerifyOnly {
-				results = append(results, Result{Source: f, Dest: destPath, Action: "skip"})
-				continue
-			}
+// opKind says what a fileOp does when it runs.
+type opKind int
 
-			destInfo, err := os.Stat(destPath)
-			exists := err == nil
-			if exists && destInfo.IsDir() {
-				res := Result{Source: f, Dest: destPath, Action: "error", Error: errors.New("destination is directory")}
-				results = append(results, res)
-				return results, res.Error
-			}
+const (
+	opResult opKind = iota // Report res as it is: an error, an unsupported file
+	opMkdir                // Create the directory res.Dest; reported only in dry runs
+	opCopy                 // Copy res.Source to res.Dest
+	opWrite                // Write the planned file's Body to res.Dest
+)
 
-			if exists && !opts.Overwrite {
-				results = append(results, Result{Source: f, Dest: destPath, Action: "skip"})
-				continue
-			}
+// fileOp is one step of applying a planned file.
+type fileOp struct {
+	kind    opKind
+	res     Result
+	primary bool // The planned file itself rather than a sibling or a file found in a source directory
+}
 
-			if dry {
-				results = append(results, Result{Source: f, Dest: destPath, Action: "copy"})
-				continue
-			}
+// plannedOps are the steps that apply one planned file, and how its source was found. A
+// planning failure is the last step.
+type plannedOps struct {
+	ops       []fileOp
+	match     sourceMatch
+	nameLimit int // Longest file name stem the target takes, for renaming clashing files
+}
 
-			if err := copyFile(f, destPath); err != nil {
-				res := Result{Source: f, Dest: destPath, Action: "error", Error: err}
-				results = append(results, res)
-				return results, res.Error
-			}
+// planFile resolves one planned file into the steps that write it, including directory
+// expansion and siblings, without touching the output.
+func planFile(ctx context.Context, p layout.PlannedFile, outputAbs string, opts Options, idx *sourceIndex) plannedOps {
+	po := plannedOps{nameLimit: model.ProfileFor(p.Target).MaxNameLen}
+	fail := func(res Result) plannedOps {
+		po.ops = append(po.ops, fileOp{kind: opResult, res: res})
+		return po
+	}
 
-			results = append(results, Result{Source: f, Dest: destPath, Action: "copy"})
-		}
+	cleanRel := path.Clean(p.Path)
+	if path.IsAbs(cleanRel) {
+		return fail(Result{Dest: cleanRel, Action: "error", Error: fmt.Errorf("%w: destination must be relative", ErrInvalidPath)})
 	}
 
-	return results, nil
-}
+	destFull := filepath.Join(outputAbs, filepath.FromSlash(cleanRel))
+	destFull = filepath.Clean(destFull)
 
-func findCaseInsensitive(root, base string) (string, error) {
-	var matches []string
-	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if strings.EqualFold(d.Name(), base) {
-			matches = append(matches, p)
-		}
-		return nil
-	})
-	if err != nil {
-		return "", err
+	if !strings.HasPrefix(destFull, outputAbs) {
+		return fail(Result{Dest: destFull, Action: "error", Error: fmt.Errorf("%w: destination escapes output root", ErrInvalidPath)})
 	}
-	if len(matches) == 0 {
-		return "", fmt.Errorf("no case-insensitive match for %s", base)
+
+	srcRel := p.Source
+	if srcRel == "" {
+		srcRel = cleanRel
 	}
-	sort.Strings(matches)
-	return matches[0], nil
-}
+	srcRelClean := path.Clean(srcRel)
+	if path.IsAbs(srcRelClean) {
+		return fail(Result{Dest: destFull, Source: srcRelClean, Action: "error", Error: fmt.Errorf("%w: source must be relative", ErrInvalidPath)})
+	}
+	srcFull := filepath.Join(opts.InputRoot, filepath.FromSlash(srcRelClean))
 
-func findCaseInsensitiveNoExt(root, base string) (string, error) {
-	baseNoExt := strings.TrimSuffix(base, filepath.Ext(base))
-	var matches []string
-	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+	po.ops = append(po.ops, fileOp{kind: opMkdir, res: Result{Source: srcFull, Dest: filepath.Dir(destFull), Action: "mkdir"}})
+
+	if p.Body != "" {
+		po.ops = append(po.ops, fileOp{kind: opWrite, primary: true, res: Result{Dest: destFull}})
+		return po
+	}
+	profile := model.ProfileFor(p.Target)
+	if p.Resolved {
+		// a resolved entry is copied exactly, refusing sources that changed size since planning
+		info, err := statSource(srcFull)
 		if err != nil {
-			return err
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("%w: %w", ErrSourceMissing, err)})
+		}
+		if info.IsDir() || (p.Size > 0 && info.Size() != p.Size) {
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("%w (%d bytes, plan has %d)", ErrSourceChanged, info.Size(), p.Size)})
+		}
+		if !profile.SupportsExtension(filepath.Ext(srcFull)) {
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "unsupported"})
+		}
+		po.ops = append(po.ops, fileOp{kind: opCopy, primary: true, res: Result{Source: srcFull, Dest: destFull}})
+		return po
+	}
+
+	allowedExts := aliasesForExt(p.Content, strings.TrimPrefix(strings.ToLower(filepath.Ext(cleanRel)), "."))
+
+	srcInfo, err := os.Stat(srcFull)
+	if err == nil {
+		po.match = sourceMatch{strategy: StrategyExact, candidates: []Candidate{{Path: srcFull, Score: scoreExact}}}
+	} else {
+		base := filepath.Base(srcRelClean)
+		dirSlug := slug(filepath.Base(filepath.Dir(cleanRel)))
+		titleSlug := slug(p.Title)
+		fileSlug := slug(strings.TrimSuffix(base, filepath.Ext(base)))
+		plannedSlug := slug(strings.TrimSuffix(filepath.Base(cleanRel), filepath.Ext(cleanRel)))
+
+		found, matchErr := idx.resolve(sourceQuery{
+			base:      base,
+			plannedIn: filepath.Base(filepath.Dir(cleanRel)),
+			dirSlugs:  []string{dirSlug, titleSlug},
+			fileSlugs: []string{fileSlug, plannedSlug},
+			anySlugs:  []string{titleSlug, fileSlug, plannedSlug},
+			exts:      allowedExts,
+		})
+		if matchErr != nil && ctx.Err() != nil {
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "cancelled", Error: cancelled(ctx)})
+		}
+		if matchErr != nil {
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("%w: %w", ErrSourceMissing, err)})
+		}
+		po.match = found
+		if opts.Strict {
+			if strictErr := found.strictError(); strictErr != nil {
+				return fail(Result{Source: found.candidates[0].Path, Dest: destFull, Action: "error", Error: fmt.Errorf("%w: %w", ErrStrict, strictErr)})
+			}
 		}
-		if d.IsDir() {
-			return nil
+		srcFull = found.candidates[0].Path
+		srcInfo, err = os.Stat(srcFull)
+		if err != nil {
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("%w after search: %w", ErrSourceMissing, err)})
 		}
-		nameNoExt := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
-		if strings.EqualFold(nameNoExt, baseNoExt) {
-			matches = append(matches, p)
+	}
+	if extract := p.Extract && strings.EqualFold(filepath.Ext(srcFull), ".zip"); srcInfo.IsDir() || extract {
+		// a source directory, or a zip the target cannot open, brings its files under their own names
+		var files []string
+		var pickErr error
+		if extract {
+			files, pickErr = archiveFiles(srcFull)
+		} else {
+			files, pickErr = pickFilesInDir(srcFull, allC64Exts())
 		}
-		return nil
-	})
-	if err != nil {
-		return "", err
+		if pickErr != nil {
+			return fail(Result{Source: srcFull, Dest: destFull, Action: "error", Error: fmt.Errorf("%w: %w", ErrSourceMissing, pickErr)})
+		}
+		destDir, nestOps, err := flatGameDir(p, outputAbs, filepath.Dir(destFull), supportedCount(profile, files))
+		po.ops = append(po.ops, nestOps...)
+		if err != nil {
+			return po
+		}
+		for _, f := range files {
+			res := Result{Source: f, Dest: filepath.Join(destDir, layout.FileName(p.Target, sourceName(f)))}
+			if !profile.SupportsExtension(filepath.Ext(f)) {
+				res.Action = "unsupported"
+				po.ops = append(po.ops, fileOp{kind: opResult, res: res})
+				continue
+			}
+			po.ops = append(po.ops, fileOp{kind: opCopy, res: res})
+		}
+		return po
 	}
-	if len(matches) == 0 {
-		return "", fmt.Errorf("no case-insensitive match (no ext) for %s", base)
+
+	// include siblings in the same directory that match C64 extensions
+	dirFiles, _ := pickFilesInDir(filepath.Dir(srcFull), allC64Exts())
+	fileSet := make(map[string]struct{})
+	var toCopy []string
+	for _, f := range append([]string{srcFull}, dirFiles...) {
+		if _, ok := fileSet[f]; ok {
+			continue
+		}
+		fileSet[f] = struct{}{}
+		toCopy = append(toCopy, f)
 	}
-	sort.Strings(matches)
-	return matches[0], nil
-}
 
-func findInMatchingDir(root, dirName string, exts []string) (string, error) {
-	if dirName == "." || dirName == "" {
-		return "", fmt.Errorf("no dir name provided")
+	// the planned file keeps its planned name (with the source's real extension) on every
+	// target, since the plan is where TheC64 flags, flat "<game>.<ext>" names, templates and
+	// collision suffixes are decided; siblings keep their own sanitized names
+	destDir, nestOps, err := flatGameDir(p, outputAbs, filepath.Dir(destFull), supportedCount(profile, toCopy))
+	po.ops = append(po.ops, nestOps...)
+	if err != nil {
+		return po
 	}
-	var candidates []string
-	walkErr := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
+	plannedStem := strings.TrimSuffix(filepath.Base(destFull), filepath.Ext(destFull))
+	for _, f := range toCopy {
+		fileName := layout.FileName(p.Target, filepath.Base(f))
+		if f == srcFull {
+			fileName = plannedStem + strings.ToLower(filepath.Ext(f))
 		}
-		if !d.IsDir() {
-			return nil
+		res := Result{Source: f, Dest: filepath.Join(destDir, fileName)}
+
+		if !profile.SupportsExtension(filepath.Ext(f)) {
+			res.Action = "unsupported"
+			po.ops = append(po.ops, fileOp{kind: opResult, res: res})
+			continue
 		}
-		if strings.EqualFold(d.Name(), dirName) {
-			entries, err := os.ReadDir(p)
-			if err != nil {
-				return err
-			}
-			for _, e := range entries {
-				if e.IsDir() {
-					continue
-				}
-				if len(exts) == 0 || hasExt(strings.TrimPrefix(filepath.Ext(e.Name()), "."), exts) {
-					candidates = append(candidates, filepath.Join(p, e.Name()))
+		po.ops = append(po.ops, fileOp{kind: opCopy, primary: f == srcFull, res: res})
+	}
+
+	return po
+}
+
+// applyPlanned runs the steps of one planned file, stopping at the first failure.
+// Every result except mkdir carries how the planned source was found.
+func applyPlanned(ctx context.Context, p layout.PlannedFile, po plannedOps, dry bool, opts Options, man *manifestState, prog *progress) ([]Result, error) {
+	var results []Result
+	var err error
+	for _, op := range po.ops {
+		var res Result
+		switch op.kind {
+		case opResult:
+			res, err = op.res, op.res.Error
+		case opMkdir:
+			if !dry {
+				if mkErr := opts.Journal.mkdirAll(op.res.Dest); mkErr != nil {
+					res = Result{Source: op.res.Source, Dest: op.res.Dest, Action: "error", Error: fmt.Errorf("%w: mkdir: %w", ErrWrite, mkErr)}
+					err = res.Error
+					break
 				}
+				continue
 			}
+			res = op.res
+		case opCopy:
+			res, err = placeFile(ctx, op.res.Source, op.res.Dest, dry, opts, man, prog)
+			res.RenamedFrom = op.res.RenamedFrom
+		case opWrite:
+			res, err = writeGenerated(p, op.res.Dest, dry, opts, man, prog)
+		}
+		results = append(results, res)
+		if err != nil {
+			break
 		}
-		return nil
-	})
-	if walkErr != nil {
-		return "", walkErr
 	}
-	if len(candidates) == 0 {
-		return "", fmt.Errorf("no match in dir %s", dirName)
+
+	if po.match.strategy != "" {
+		for i := range results {
+			if results[i].Action != "mkdir" {
+				po.match.stamp(&results[i])
+			}
+		}
 	}
-	sort.Strings(candidates)
-	return candidates[0], nil
+	return results, err
 }
 
-func findAnyBySlug(root string, fileSlugs []string, exts []string) (string, error) {
-	var candidates []string
-	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if d.IsDir() {
-			return nil
-		}
-		if len(exts) > 0 && !hasExt(strings.TrimPrefix(filepath.Ext(d.Name()), "."), exts) {
-			return nil
+// placeFile copies one source file to dest. A destination that already holds the source as
+// recorded in the manifest is "unchanged"; one written by an earlier run from a source that
+// has since changed is replaced; any other existing file is only replaced with Overwrite.
+func placeFile(ctx context.Context, src, dest string, dry bool, opts Options, man *manifestState, prog *progress) (Result, error) {
+	if opts.VerifyOnly {
+		return Result{Source: src, Dest: dest, Action: "skip"}, nil
+	}
+
+	srcInfo, err := statSource(src)
+	if err != nil {
+		res := Result{Source: src, Dest: dest, Action: "error", Error: fmt.Errorf("%w: %w", ErrSourceMissing, err)}
+		return res, res.Error
+	}
+
+	destInfo, err := os.Stat(dest)
+	exists := err == nil
+	if exists && destInfo.IsDir() {
+		res := Result{Source: src, Dest: dest, Action: "error", Error: fmt.Errorf("%w: destination is a directory", ErrWrite)}
+		return res, res.Error
+	}
+	if exists {
+		if man.unchanged(src, dest, srcInfo, destInfo) {
+			return Result{Source: src, Dest: dest, Action: "unchanged"}, nil
 		}
-		ns := slug(strings.TrimSuffix(d.Name(), filepath.Ext(d.Name())))
-		if slugMatch(ns, fileSlugs) {
-			candidates = append(candidates, p)
+		if _, ours := man.owned(dest, destInfo); !ours && !opts.Overwrite {
+			return Result{Source: src, Dest: dest, Action: "skip"}, nil
 		}
-		return nil
-	})
-	if err != nil {
-		return "", err
 	}
-	if len(candidates) == 0 {
-		return "", fmt.Errorf("no slug match anywhere")
+
+	if dry {
+		return Result{Source: src, Dest: dest, Action: "copy"}, nil
+	}
+
+	sum, err := copyFile(ctx, src, dest, opts.Journal, prog)
+	if err != nil && ctx.Err() != nil {
+		res := Result{Source: src, Dest: dest, Action: "cancelled", Error: cancelled(ctx)}
+		return res, res.Error
 	}
-	sort.Strings(candidates)
-	return candidates[0], nil
+	if err != nil {
+		res := Result{Source: src, Dest: dest, Action: "error", Error: fmt.Errorf("%w: %w", ErrWrite, err)}
+		return res, res.Error
+	}
+	man.record(src, dest, srcInfo.Size(), srcInfo.ModTime(), sum)
+	return Result{Source: src, Dest: dest, Action: "copy"}, nil
 }
 
-func findAnyByExt(root string, exts []string) (string, error) {
-	if len(exts) == 0 {
-		return "", fmt.Errorf("no extension provided")
+// flatGameDir returns the directory a planned file's files go to, and the step that creates it.
+// Flat single-file games stay in their bucket unless the source brings companions, in which case
+// they move into p.GameDir.
+func flatGameDir(p layout.PlannedFile, outputAbs, destDir string, files int) (string, []fileOp, error) {
+	if p.GameDir == "" || files <= 1 {
+		return destDir, nil, nil
 	}
-	var matches []string
-	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
+	gameDir := filepath.Join(outputAbs, filepath.FromSlash(path.Clean(p.GameDir)))
+	if !strings.HasPrefix(gameDir, outputAbs) {
+		res := Result{Dest: gameDir, Action: "error", Error: fmt.Errorf("%w: destination escapes output root", ErrInvalidPath)}
+		return destDir, []fileOp{{kind: opResult, res: res}}, res.Error
+	}
+	return gameDir, []fileOp{{kind: opMkdir, res: Result{Dest: gameDir, Action: "mkdir"}}}, nil
+}
+
+// supportedCount counts the files the target device can open.
+func supportedCount(profile model.TargetProfile, files []string) int {
+	n := 0
+	for _, f := range files {
+		if profile.SupportsExtension(filepath.Ext(f)) {
+			n++
 		}
-		if d.IsDir() {
-			return nil
+	}
+	return n
+}
+
+// writeGenerated writes a planned file whose content was generated rather than copied from the input.
+func writeGenerated(p layout.PlannedFile, destFull string, dry bool, opts Options, man *manifestState, prog *progress) (Result, error) {
+	if opts.VerifyOnly {
+		return Result{Dest: destFull, Action: "skip"}, nil
+	}
+
+	destInfo, err := os.Stat(destFull)
+	exists := err == nil
+	if exists && destInfo.IsDir() {
+		res := Result{Dest: destFull, Action: "error", Error: fmt.Errorf("%w: destination is a directory", ErrWrite)}
+		return res, res.Error
+	}
+	sum := hashBytes([]byte(p.Body))
+	if exists {
+		entry, ours := man.owned(destFull, destInfo)
+		if ours && entry.Source == "" && entry.SHA256 == sum {
+			return Result{Dest: destFull, Action: "unchanged"}, nil
 		}
-		if hasExt(strings.TrimPrefix(filepath.Ext(d.Name()), "."), exts) {
-			matches = append(matches, p)
+		if !ours && !opts.Overwrite {
+			return Result{Dest: destFull, Action: "skip"}, nil
 		}
-		return nil
+	}
+	if dry {
+		return Result{Dest: destFull, Action: "write"}, nil
+	}
+
+	err = opts.Journal.writeAtomic(destFull, func(w io.Writer) error {
+		_, err := io.WriteString(w, p.Body)
+		return err
 	})
 	if err != nil {
-		return "", err
-	}
-	if len(matches) == 0 {
-		return "", fmt.Errorf("no files with allowed extensions")
+		res := Result{Dest: destFull, Action: "error", Error: fmt.Errorf("%w: %w", ErrWrite, err)}
+		return res, res.Error
 	}
-	sort.Strings(matches)
-	return matches[0], nil
+	man.record("", destFull, int64(len(p.Body)), time.Time{}, sum)
+	prog.copied(int64(len(p.Body)))
+	return Result{Dest: destFull, Action: "write"}, nil
 }
 
 func allC64Exts() []string {
@@ -373,95 +530,6 @@ func pickFilesInDir(dir string, exts []string) ([]string, error) {
 	return matches, nil
 }
 
-func sanitizeFileName(name string) string {
-	base := strings.TrimSpace(name)
-	ext := filepath.Ext(base)
-	base = strings.TrimSuffix(base, ext)
-	runes := []rune(base)
-	var b strings.Builder
-	last := rune(0)
-	for _, r := range runes {
-		switch {
-		case r == '_':
-			r = ' '
-		case r == '\'':
-			continue
-		case (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == ' ':
-			if r >= 'A' && r <= 'Z' {
-				r = r - 'A' + 'a'
-			}
-		default:
-			r = '-'
-		}
-		if (r == ' ' || r == '-') && (last == ' ' || last == '-') {
-			continue
-		}
-		b.WriteRune(r)
-		last = r
-	}
-	clean := strings.Trim(b.String(), " -")
-	if ext != "" {
-		return clean + strings.ToLower(ext)
-	}
-	return clean
-}
-
-func findBySlug(root string, dirSlugs []string, fileSlugs []string, exts []string) (string, error) {
-	if len(dirSlugs) == 0 || len(fileSlugs) == 0 {
-		return "", fmt.Errorf("missing slug")
-	}
-
-	var candidates []string
-	var extOnly []string
-
-	walkErr := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		if !d.IsDir() {
-			return nil
-		}
-		if !slugMatch(slug(d.Name()), dirSlugs) {
-			return nil
-		}
-
-		entries, err := os.ReadDir(p)
-		if err != nil {
-			return err
-		}
-		for _, e := range entries {
-			if e.IsDir() {
-				continue
-			}
-			ns := slug(strings.TrimSuffix(e.Name(), filepath.Ext(e.Name())))
-			if slugMatch(ns, fileSlugs) {
-				if len(exts) == 0 || hasExt(strings.TrimPrefix(filepath.Ext(e.Name()), "."), exts) {
-					candidates = append(candidates, filepath.Join(p, e.Name()))
-				}
-				continue
-			}
-			if len(exts) == 0 || hasExt(strings.TrimPrefix(filepath.Ext(e.Name()), "."), exts) {
-				extOnly = append(extOnly, filepath.Join(p, e.Name()))
-			}
-		}
-
-		return nil
-	})
-	if walkErr != nil {
-		return "", walkErr
-	}
-	if len(candidates) == 0 {
-		if len(extOnly) == 0 {
-			return "", fmt.Errorf("no slug match")
-		}
-		sort.Strings(extOnly)
-		return extOnly[0], nil
-	}
-
-	sort.Strings(candidates)
-	return candidates[0], nil
-}
-
 func slugIn(s string, arr []string) bool {
 	for _, a := range arr {
 		if a != "" && s == a {
@@ -471,18 +539,6 @@ func slugIn(s string, arr []string) bool {
 	return false
 }
 
-func slugMatch(s string, arr []string) bool {
-	for _, a := range arr {
-		if a == "" {
-			continue
-		}
-		if s == a || strings.Contains(s, a) || strings.Contains(a, s) {
-			return true
-		}
-	}
-	return false
-}
-
 func hasExt(ext string, allowed []string) bool {
 	for _, a := range allowed {
 		if strings.EqualFold(ext, a) {
@@ -544,22 +600,42 @@ func slug(s string) string {
 	return b.String()
 }
 
-func copyFile(src, dest string) error {
-	srcFile, err := os.Open(src)
+// copyFile atomically copies src to dest and returns the SHA-256 of the copied content.
+// Cancelling ctx aborts the copy and leaves dest as it was. Copied bytes are reported to prog.
+func copyFile(ctx context.Context, src, dest string, j *Journal, prog *progress) (string, error) {
+	srcFile, err := openSource(src)
 	if err != nil {
-		return fmt.Errorf("open source: %w", err)
+		return "", fmt.Errorf("open source: %w", err)
 	}
 	defer srcFile.Close()
 
-	destFile, err := os.Create(dest)
+	h := sha256.New()
+	err = j.writeAtomic(dest, func(w io.Writer) error {
+		if _, err := io.Copy(io.MultiWriter(w, h), ctxReader{ctx: ctx, r: srcFile, prog: prog}); err != nil {
+			return fmt.Errorf("copy: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("create dest: %w", err)
+		return "", err
 	}
-	defer destFile.Close()
 
-	if _, err := io.Copy(destFile, srcFile); err != nil {
-		return fmt.Errorf("copy: %w", err)
-	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	return nil
+// ctxReader fails reads once its context is done, so long copies stop promptly, and reports
+// the bytes read to prog.
+type ctxReader struct {
+	ctx  context.Context
+	r    io.Reader
+	prog *progress
+}
+
+func (c ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := c.r.Read(p)
+	c.prog.copied(int64(n))
+	return n, err
 }
@@ -1,6 +1,8 @@
 package executor
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -23,7 +25,7 @@ func TestApplyDryRun(t *testing.T) {
 		Target: model.TargetSD2IEC,
 	}}
 
-	results, err := Apply(planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true})
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true})
 	if err != nil {
 		t.Fatalf("Apply returned error: %v", err)
 	}
@@ -51,7 +53,7 @@ func TestApplyRealCopy(t *testing.T) {
 		Target: model.TargetSD2IEC,
 	}}
 
-	results, err := Apply(planned, Options{InputRoot: src, OutputRoot: dst, DryRun: false})
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, DryRun: false})
 	if err != nil {
 		t.Fatalf("Apply returned error: %v", err)
 	}
@@ -85,7 +87,7 @@ func TestApplyOverwriteProtection(t *testing.T) {
 		Target: model.TargetSD2IEC,
 	}}
 
-	results, err := Apply(planned, Options{InputRoot: src, OutputRoot: dst, Overwrite: false})
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, Overwrite: false})
 	if err != nil {
 		t.Fatalf("Apply returned error: %v", err)
 	}
@@ -99,7 +101,7 @@ func TestApplyOverwriteProtection(t *testing.T) {
 		t.Fatalf("existing file should remain untouched")
 	}
 
-	_, err = Apply(planned, Options{InputRoot: src, OutputRoot: dst, Overwrite: true})
+	_, err = Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, Overwrite: true})
 	if err != nil {
 		t.Fatalf("Apply returned error on overwrite: %v", err)
 	}
@@ -124,7 +126,7 @@ func TestApplyDeterministicOrder(t *testing.T) {
 		{GameID: "a", Path: "a/a.d64", Target: model.TargetSD2IEC},
 	}
 
-	results, err := Apply(planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true})
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true})
 	if err != nil {
 		t.Fatalf("Apply returned error: %v", err)
 	}
@@ -134,6 +136,196 @@ func TestApplyDeterministicOrder(t *testing.T) {
 	}
 }
 
+func TestApplyLeavesOutUnsupportedSiblings(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "game/disk1.d64"), []byte("disk"))
+	mustWrite(t, filepath.Join(src, "game/game.crt"), []byte("cart"))
+
+	planned := []layout.PlannedFile{{
+		GameID:  "g1",
+		Path:    "game/disk1.d64",
+		Target:  model.TargetSD2IEC,
+		Content: model.ContentDisk,
+	}}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	actions := map[string]string{}
+	for _, r := range results {
+		actions[filepath.Base(r.Source)] = r.Action
+	}
+	if actions["disk1.d64"] != "copy" || actions["game.crt"] != "unsupported" {
+		t.Fatalf("unexpected actions: %v", actions)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "game", "game.crt")); err == nil {
+		t.Fatalf("unsupported sibling should not be copied")
+	}
+}
+
+func TestApplyUsesPlannedNameForPrimary(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Paradroid/Paradroid.d64"), []byte("disk"))
+
+	planned := []layout.PlannedFile{{
+		GameID:  "paradroid",
+		Source:  "Paradroid/Paradroid.d64",
+		Path:    "p/paradroid/paradroid_J1.d64",
+		Target:  model.TargetTheC64,
+		Content: model.ContentDisk,
+	}}
+
+	if _, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dst, "p", "paradroid", "paradroid_J1.d64")); err != nil {
+		t.Fatalf("expected primary written under planned name: %v", err)
+	}
+}
+
+func TestApplyUsesPlannedNameOnEveryTarget(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	mustWrite(t, filepath.Join(src, "Elite/Elite_v2.prg"), []byte("prg"))
+
+	for _, tc := range []struct {
+		target model.TargetDevice
+		path   string
+	}{
+		{model.TargetSD2IEC, "e/elite/elite~1.prg"}, // collision suffix from the plan
+		{model.TargetUltimate, "e/Elite.prg"},       // flat game named after the game
+		{model.TargetKungFuFlash, "prg/elite.prg"},  // path template
+	} {
+		dst := filepath.Join(root, string(tc.target))
+		planned := []layout.PlannedFile{{GameID: "elite", Source: "Elite/Elite_v2.prg", Path: tc.path, Target: tc.target, Content: model.ContentPrg}}
+		if _, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst}); err != nil {
+			t.Fatalf("%s: Apply returned error: %v", tc.target, err)
+		}
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(tc.path))); err != nil {
+			t.Fatalf("%s: expected primary written under planned name: %v", tc.target, err)
+		}
+	}
+}
+
+func TestApplyNestsFlatGameWithCompanions(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Elite/elite.prg"), []byte("prg"))
+	mustWrite(t, filepath.Join(src, "Elite/elite save.prg"), []byte("save"))
+	mustWrite(t, filepath.Join(src, "Hero/hero.prg"), []byte("prg"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "elite", Source: "Elite/elite.prg", Path: "e/elite.prg", GameDir: "e/elite", Target: model.TargetUltimate, Content: model.ContentPrg},
+		{GameID: "hero", Source: "Hero/hero.prg", Path: "h/hero.prg", GameDir: "h/hero", Target: model.TargetUltimate, Content: model.ContentPrg},
+	}
+
+	if _, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	for _, rel := range []string{"e/elite/elite.prg", "e/elite/elite save.prg", "h/hero.prg"} {
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(rel))); err != nil {
+			t.Fatalf("expected %s: %v", rel, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dst, "e", "elite.prg")); err == nil {
+		t.Fatalf("game with companions should not stay flat")
+	}
+}
+
+func TestApplyKeepGoingCollectsFailures(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "b/beta.d64"), []byte("beta"))
+	mustWrite(t, filepath.Join(src, "c/gamma.d64"), []byte("gamma"))
+	mustMkdir(t, filepath.Join(dst, "c", "gamma.d64"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "alpha", VariantID: "alpha", Path: "a/alpha.prg", Target: model.TargetSD2IEC, Content: model.ContentPrg},
+		{GameID: "beta", VariantID: "beta", Path: "b/beta.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+		{GameID: "gamma", VariantID: "gamma", Path: "c/gamma.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+		{GameID: "delta", VariantID: "delta", Path: "d/delta.prg", Target: model.TargetSD2IEC, Content: model.ContentPrg},
+	}
+
+	if _, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst}); !errors.Is(err, ErrSourceMissing) {
+		t.Fatalf("without keep-going Apply should stop at the first missing source, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "b", "beta.d64")); err == nil {
+		t.Fatalf("files after the first failure should not be copied without keep-going")
+	}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, KeepGoing: true})
+	var failed *Failures
+	if !errors.As(err, &failed) {
+		t.Fatalf("expected *Failures, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "b", "beta.d64")); err != nil {
+		t.Fatalf("keep-going should copy files after a failure: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("expected a result per planned file, got %+v", results)
+	}
+
+	groups := failed.Groups()
+	if len(groups) != 2 || groups[0].Category != ErrSourceMissing.Error() || len(groups[0].Results) != 2 ||
+		groups[1].Category != ErrWrite.Error() || len(groups[1].Results) != 1 {
+		t.Fatalf("unexpected failure groups: %+v", groups)
+	}
+	if groups[0].Results[0].VariantID != "alpha" || groups[0].Results[1].VariantID != "delta" {
+		t.Fatalf("failures should keep plan order: %+v", groups[0].Results)
+	}
+	if !errors.Is(err, ErrWrite) {
+		t.Fatalf("errors.Is should match every failure category")
+	}
+	if got := err.Error(); got != "3 files failed (2 source missing, 1 write failed)" {
+		t.Fatalf("unexpected error text %q", got)
+	}
+}
+
+func TestApplyCancelled(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+	mustWrite(t, filepath.Join(src, "game/disk1.d64"), []byte("data"))
+
+	planned := []layout.PlannedFile{{GameID: "g1", Path: "game/disk1.d64", Target: model.TargetSD2IEC}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, jobs := range []int{1, 4} {
+		results, err := Apply(ctx, planned, Options{InputRoot: src, OutputRoot: dst, Jobs: jobs})
+		if !errors.Is(err, ErrCancelled) || !errors.Is(err, context.Canceled) {
+			t.Fatalf("jobs=%d: expected a cancelled error, got %v", jobs, err)
+		}
+		if len(results) != 0 {
+			t.Fatalf("jobs=%d: nothing should run after cancellation, got %+v", jobs, results)
+		}
+	}
+
+	// a copy cut short leaves neither the destination nor a temporary file behind
+	dest := filepath.Join(dst, "disk1.d64")
+	mustMkdir(t, dst)
+	if _, err := copyFile(ctx, filepath.Join(src, "game/disk1.d64"), dest, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected copy to stop, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dst); len(entries) != 0 {
+		t.Fatalf("cancelled copy left files behind: %v", entries)
+	}
+}
+
 func mustMkdir(t *testing.T, dir string) {
 	t.Helper()
 	if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -141,7 +333,7 @@ func mustMkdir(t *testing.T, dir string) {
 	}
 }
 
-func mustWrite(t *testing.T, path string, data []byte) {
+func mustWrite(t testing.TB, path string, data []byte) {
 	t.Helper()
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		t.Fatalf("mkdir for file: %v", err)
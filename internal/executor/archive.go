@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// memberSep joins a zip archive and one of its members into a single source path, e.g.
+// "games/alpha.zip!alpha.d64". Results, the manifest and resolved plans use this form for
+// files extracted from archives.
+const memberSep = "!"
+
+// archiveMember splits a source path into its zip archive and member; ok is false for plain files.
+func archiveMember(src string) (archive, member string, ok bool) {
+	i := strings.Index(strings.ToLower(src), ".zip"+memberSep)
+	if i < 0 {
+		return "", "", false
+	}
+	return src[:i+len(".zip")], src[i+len(".zip"+memberSep):], true
+}
+
+// sourceName is the file name of a source path, the member's own name for archive members.
+func sourceName(src string) string {
+	if _, member, ok := archiveMember(src); ok {
+		return path.Base(member)
+	}
+	return filepath.Base(src)
+}
+
+// statSource is os.Stat for a source path that may name an archive member.
+func statSource(src string) (fs.FileInfo, error) {
+	archive, member, ok := archiveMember(src)
+	if !ok {
+		return os.Stat(src)
+	}
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return fs.Stat(r, member)
+}
+
+// openSource is os.Open for a source path that may name an archive member.
+func openSource(src string) (io.ReadCloser, error) {
+	archive, member, ok := archiveMember(src)
+	if !ok {
+		return os.Open(src)
+	}
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.Open(member)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return memberReader{File: f, archive: r}, nil
+}
+
+// memberReader closes the archive together with the member read from it.
+type memberReader struct {
+	fs.File
+	archive *zip.ReadCloser
+}
+
+func (m memberReader) Close() error {
+	err := m.File.Close()
+	if closeErr := m.archive.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// archiveFiles lists the C64 files inside a zip archive as source paths, in name order. Nested
+// archives are left out since they cannot be extracted in one step.
+func archiveFiles(archive string) ([]string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var files []string
+	for _, f := range r.File {
+		ext := strings.TrimPrefix(path.Ext(f.Name), ".")
+		if f.FileInfo().IsDir() || strings.EqualFold(ext, "zip") || !hasExt(ext, allC64Exts()) || !fs.ValidPath(f.Name) {
+			continue
+		}
+		files = append(files, archive+memberSep+f.Name)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("archive holds no C64 files")
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func mustZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	mustMkdir(t, filepath.Dir(path))
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, data := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("add %s: %v", name, err)
+		}
+		if _, err := fw.Write([]byte(data)); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close %s: %v", path, err)
+	}
+}
+
+func TestApplyExtractsArchives(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustZip(t, filepath.Join(src, "alpha/Alpha.zip"), map[string]string{
+		"Alpha_1.d64":      "side a",
+		"disks/alpha2.d64": "side b",
+		"alpha.crt":        "cart",
+		"readme.txt":       "docs",
+	})
+	planned := []layout.PlannedFile{{
+		GameID: "alpha", VariantID: "alpha-0", Source: "alpha/Alpha.zip", Path: "a/alpha/alpha.zip",
+		Target: model.TargetSD2IEC, Content: model.ContentZip, Extract: true,
+	}}
+	opts := Options{InputRoot: src, OutputRoot: dst}
+
+	results, err := Apply(context.Background(), planned, opts)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	want := map[string]string{"a/alpha/alpha 1.d64": "copy", "a/alpha/alpha2.d64": "copy", "a/alpha/alpha.crt": "unsupported"}
+	if got := actionsByDest(results, dst); !equalActions(got, want) {
+		t.Fatalf("actions = %v, want %v", got, want)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "a/alpha/alpha2.d64")); err != nil || string(data) != "side b" {
+		t.Fatalf("extracted file = %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a/alpha/alpha.zip")); err == nil {
+		t.Fatalf("the archive itself should not be copied")
+	}
+
+	// the manifest knows the extracted files, so a second run leaves them alone
+	results, err = Apply(context.Background(), planned, opts)
+	if err != nil {
+		t.Fatalf("second Apply returned error: %v", err)
+	}
+	want = map[string]string{"a/alpha/alpha 1.d64": "unchanged", "a/alpha/alpha2.d64": "unchanged", "a/alpha/alpha.crt": "unsupported"}
+	if got := actionsByDest(results, dst); !equalActions(got, want) {
+		t.Fatalf("second run actions = %v, want %v", got, want)
+	}
+
+	// resolved entries name the archive member and extract it again when applied
+	resolved, _, err := Resolve(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, Overwrite: true})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if len(resolved) != 2 || resolved[0].Source != "alpha/Alpha.zip!Alpha_1.d64" || resolved[0].Size != int64(len("side a")) {
+		t.Fatalf("unexpected resolved entries: %+v", resolved)
+	}
+	fresh := filepath.Join(root, "fresh")
+	if _, err := Apply(context.Background(), resolved, Options{InputRoot: src, OutputRoot: fresh}); err != nil {
+		t.Fatalf("applying the resolved plan returned error: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(fresh, "a/alpha/alpha 1.d64")); err != nil || string(data) != "side a" {
+		t.Fatalf("extracted file from resolved plan = %q, %v", data, err)
+	}
+}
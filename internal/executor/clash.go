@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+// resolveClashes finds destinations that two different sources would be written to in this
+// run, comparing paths case-insensitively as FAT does, e.g. siblings "Disk_1.d64" and
+// "disk 1.d64", which both sanitize to "disk 1.d64". Planned files claim their paths before
+// siblings and files found in source directories, in plan order. A later sibling or expanded
+// file is renamed with a "~n" suffix ("disk 1~1.d64") within the target's name limit, as other
+// name collisions are, and keeps the original path in RenamedFrom; a later planned file fails
+// with ErrClash. The same source placed at the same
+// path twice, as when variants sharing a folder copy each other's siblings, is no clash.
+func resolveClashes(plans []plannedOps) {
+	claims := make(map[string]string)  // lowercased destination -> source that claimed it; empty for generated files
+	renames := make(map[string]string) // lowercased destination and source -> the name it was renamed to
+	for _, primaries := range []bool{true, false} {
+		for i := range plans {
+			for k := range plans[i].ops {
+				op := &plans[i].ops[k]
+				if (op.kind != opCopy && op.kind != opWrite) || op.primary != primaries {
+					continue
+				}
+				key := strings.ToLower(op.res.Dest)
+				claimedBy, taken := claims[key]
+				switch {
+				case !taken:
+					claims[key] = op.res.Source
+				case op.kind == opCopy && claimedBy == op.res.Source:
+					// already placed by an earlier step
+				case op.primary:
+					op.kind = opResult
+					op.res.Action = "error"
+					op.res.Error = fmt.Errorf("%w: %s is already written from %s", ErrClash, op.res.Dest, describeSource(claimedBy))
+				default:
+					// a source renamed for another variant keeps the same new name
+					renamedKey := key + "\x00" + op.res.Source
+					renamed, ok := renames[renamedKey]
+					if !ok {
+						renamed = freeName(op.res.Dest, plans[i].nameLimit, claims)
+						claims[strings.ToLower(renamed)] = op.res.Source
+						renames[renamedKey] = renamed
+					}
+					op.res.RenamedFrom, op.res.Dest = op.res.Dest, renamed
+				}
+			}
+		}
+	}
+}
+
+// dropPlannedSiblings removes sibling and expanded-file copies of sources that a planned file
+// already places as its primary, so a multi-disk game whose disks are planned one per variant
+// gets each disk once, under its planned name, rather than again under the sibling's own name.
+func dropPlannedSiblings(plans []plannedOps) {
+	primaries := make(map[string]struct{})
+	for _, po := range plans {
+		for _, op := range po.ops {
+			if op.kind == opCopy && op.primary {
+				primaries[op.res.Source] = struct{}{}
+			}
+		}
+	}
+	for i := range plans {
+		ops := plans[i].ops[:0]
+		for _, op := range plans[i].ops {
+			if _, planned := primaries[op.res.Source]; planned && op.kind == opCopy && !op.primary {
+				continue
+			}
+			ops = append(ops, op)
+		}
+		plans[i].ops = ops
+	}
+}
+
+// freeName adds the lowest "~n" suffix, from 1, that makes dest unclaimed, shortening the file
+// name stem so it stays within limit runes (see layout.SuffixStem); zero means no limit.
+func freeName(dest string, limit int, claims map[string]string) string {
+	dir, base := filepath.Split(dest)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for n := 1; ; n++ {
+		name := dir + layout.SuffixStem(stem, n, limit) + ext
+		if _, taken := claims[strings.ToLower(name)]; !taken {
+			return name
+		}
+	}
+}
+
+func describeSource(source string) string {
+	if source == "" {
+		return "a generated file"
+	}
+	return source
+}
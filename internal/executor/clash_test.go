@@ -0,0 +1,118 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestApplyRenamesClashingSiblings(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "game/Game_v2.d64"), []byte("primary"))
+	mustWrite(t, filepath.Join(src, "game/game.d64"), []byte("old version"))
+	mustWrite(t, filepath.Join(src, "game/Disk_1.d64"), []byte("disk one"))
+	mustWrite(t, filepath.Join(src, "game/disk 1.d64"), []byte("disk one again"))
+	mustWrite(t, filepath.Join(src, "game/Disk_2.d64"), []byte("disk two"))
+	mustWrite(t, filepath.Join(src, "game/disk 2.d64"), []byte("disk two again"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "game", VariantID: "game", Path: "game/game.d64", Source: "game/Game_v2.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+		{GameID: "game", VariantID: "game-alt", Path: "game/alt.d64", Source: "game/Disk_1.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+	}
+
+	for _, jobs := range []int{1, 4} {
+		out := filepath.Join(dst, map[int]string{1: "seq", 4: "par"}[jobs])
+		results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: out, Jobs: jobs})
+		if err != nil {
+			t.Fatalf("jobs=%d: Apply returned error: %v", jobs, err)
+		}
+
+		// alt comes first in plan order, so its siblings claim their names first; the planned
+		// game.d64 still outranks the sibling of the same name, and Disk_1.d64 is only copied as
+		// the planned alt.d64
+		want := map[string]string{
+			"game/game.d64":     "primary",
+			"game/game~1.d64":   "old version",
+			"game/disk 1.d64":   "disk one again",
+			"game/disk 2.d64":   "disk two",
+			"game/disk 2~1.d64": "disk two again",
+			"game/alt.d64":      "disk one",
+		}
+		for rel, content := range want {
+			data, err := os.ReadFile(filepath.Join(out, rel))
+			if err != nil || string(data) != content {
+				t.Fatalf("jobs=%d: %s = %q, %v; want %q", jobs, rel, data, err, content)
+			}
+		}
+
+		renamed := make(map[string]string)
+		for _, r := range results {
+			if r.Action == "error" {
+				t.Fatalf("jobs=%d: unexpected error result %+v", jobs, r)
+			}
+			if r.RenamedFrom != "" {
+				from, _ := filepath.Rel(out, r.RenamedFrom)
+				to, _ := filepath.Rel(out, r.Dest)
+				renamed[filepath.ToSlash(to)] = filepath.ToSlash(from)
+			}
+		}
+		// the second variant copies the same siblings again; that is no clash
+		wantRenamed := map[string]string{"game/game~1.d64": "game/game.d64", "game/disk 2~1.d64": "game/disk 2.d64"}
+		if !equalActions(renamed, wantRenamed) {
+			t.Fatalf("jobs=%d: renamed = %v, want %v", jobs, renamed, wantRenamed)
+		}
+	}
+}
+
+func TestApplyReportsClashingPlannedFiles(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "a/Alpha.prg"), []byte("alpha"))
+	mustWrite(t, filepath.Join(src, "b/alpha.prg"), []byte("other alpha"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "a", VariantID: "a", Path: "games/Alpha.prg", Source: "a/Alpha.prg", Target: model.TargetSD2IEC, Content: model.ContentPrg},
+		{GameID: "b", VariantID: "b", Path: "games/alpha.prg", Source: "b/alpha.prg", Target: model.TargetSD2IEC, Content: model.ContentPrg},
+	}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true, KeepGoing: true})
+	var failed *Failures
+	if !errors.As(err, &failed) || !errors.Is(err, ErrClash) {
+		t.Fatalf("expected a *Failures with ErrClash, got %v", err)
+	}
+	if len(failed.Results) != 1 || failed.Results[0].VariantID != "b" {
+		t.Fatalf("the later planned file should fail, got %+v", failed.Results)
+	}
+	copies := 0
+	for _, r := range results {
+		if r.Action == "copy" {
+			copies++
+		}
+	}
+	if copies != 1 {
+		t.Fatalf("expected only the first planned file to be copied, got %d copies", copies)
+	}
+}
+
+func TestFreeNameKeepsWithinNameLimit(t *testing.T) {
+	claims := map[string]string{
+		"game/long disk name 1.d64": "a",
+		"game/long disk name~1.d64": "b",
+	}
+	if got := freeName("game/Long Disk Name 1.d64", 16, claims); got != "game/Long Disk Name~2.d64" {
+		t.Fatalf("freeName within 16 = %q", got)
+	}
+	if got := freeName("game/long disk name 1.d64", 0, claims); got != "game/long disk name 1~1.d64" {
+		t.Fatalf("freeName without limit = %q", got)
+	}
+}
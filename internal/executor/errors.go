@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Failure categories. Every error result wraps one of these so callers can group failures.
+var (
+	ErrInvalidPath   = errors.New("invalid path")
+	ErrSourceMissing = errors.New("source missing")
+	ErrStrict        = errors.New("strict")
+	ErrSourceChanged = errors.New("source changed since planning")
+	ErrWrite         = errors.New("write failed")
+	ErrClash         = errors.New("destination clash")
+)
+
+// ErrCancelled is returned, wrapping the context's error, when a run is cancelled part way.
+// The results gathered until then are returned with it.
+var ErrCancelled = errors.New("run cancelled")
+
+func cancelled(ctx context.Context) error {
+	return fmt.Errorf("%w: %w", ErrCancelled, context.Cause(ctx))
+}
+
+var categories = []error{ErrSourceMissing, ErrStrict, ErrSourceChanged, ErrInvalidPath, ErrClash, ErrWrite}
+
+// Category names the failure category of an error result's error, or "other".
+func Category(err error) string {
+	for _, c := range categories {
+		if errors.Is(err, c) {
+			return c.Error()
+		}
+	}
+	return "other"
+}
+
+// FailureGroup holds the failed results of one category.
+type FailureGroup struct {
+	Category string
+	Results  []Result
+}
+
+// Failures is returned by Apply in keep-going mode when any planned file failed. Its Results
+// are the error results in plan order; errors.Is matches the categories of every failure.
+type Failures struct {
+	Results []Result
+}
+
+// CollectFailures returns a *Failures for the error results, or nil if there are none.
+func CollectFailures(results []Result) error {
+	var failed []Result
+	for _, r := range results {
+		if r.Action == "error" {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &Failures{Results: failed}
+}
+
+// Groups returns the failures by category, in a fixed category order.
+func (f *Failures) Groups() []FailureGroup {
+	byCategory := make(map[string][]Result)
+	for _, r := range f.Results {
+		c := Category(r.Error)
+		byCategory[c] = append(byCategory[c], r)
+	}
+	names := make([]string, 0, len(categories)+1)
+	for _, c := range categories {
+		names = append(names, c.Error())
+	}
+	var groups []FailureGroup
+	for _, name := range append(names, "other") {
+		if rs, ok := byCategory[name]; ok {
+			groups = append(groups, FailureGroup{Category: name, Results: rs})
+		}
+	}
+	return groups
+}
+
+func (f *Failures) Error() string {
+	var parts []string
+	for _, g := range f.Groups() {
+		parts = append(parts, fmt.Sprintf("%d %s", len(g.Results), g.Category))
+	}
+	noun := "files"
+	if len(f.Results) == 1 {
+		noun = "file"
+	}
+	return fmt.Sprintf("%d %s failed (%s)", len(f.Results), noun, strings.Join(parts, ", "))
+}
+
+func (f *Failures) Unwrap() []error {
+	errs := make([]error, 0, len(f.Results))
+	for _, r := range f.Results {
+		errs = append(errs, r.Error)
+	}
+	return errs
+}
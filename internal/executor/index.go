@@ -0,0 +1,221 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// sourceIndex is an in-memory view of the input tree. Apply builds it once, the first time a
+// planned source is not at its literal path, and resolves every fuzzy lookup against it instead
+// of walking the tree again. It is safe for concurrent lookups; the first one builds it.
+type sourceIndex struct {
+	ctx  context.Context // the Apply run's context; cancelling it stops the walk
+	root string
+	once sync.Once
+	err  error
+
+	files       []indexedFile // in walk (lexical) order
+	dirs        []indexedDir  // in walk order, including the root
+	byName      map[string][]int
+	byStem      map[string][]int
+	byExt       map[string][]int
+	dirsByName  map[string][]int
+	filesInDirs map[string][]int
+}
+
+type indexedFile struct {
+	path string
+	dir  string
+	ext  string // lowercase, without the dot
+	slug string // slug of the name without extension
+}
+
+type indexedDir struct {
+	path string
+	slug string
+}
+
+func newSourceIndex(ctx context.Context, root string) *sourceIndex {
+	return &sourceIndex{ctx: ctx, root: root}
+}
+
+// load walks the input tree on first use.
+func (ix *sourceIndex) load() error {
+	ix.once.Do(ix.build)
+	return ix.err
+}
+
+func (ix *sourceIndex) build() {
+	ix.byName = make(map[string][]int)
+	ix.byStem = make(map[string][]int)
+	ix.byExt = make(map[string][]int)
+	ix.dirsByName = make(map[string][]int)
+	ix.filesInDirs = make(map[string][]int)
+
+	ix.err = filepath.WalkDir(ix.root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ix.ctx.Err(); err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			ix.dirsByName[strings.ToLower(name)] = append(ix.dirsByName[strings.ToLower(name)], len(ix.dirs))
+			ix.dirs = append(ix.dirs, indexedDir{path: p, slug: slug(name)})
+			return nil
+		}
+		ext := filepath.Ext(name)
+		stem := strings.TrimSuffix(name, ext)
+		f := indexedFile{
+			path: p,
+			dir:  filepath.Dir(p),
+			ext:  strings.ToLower(strings.TrimPrefix(ext, ".")),
+			slug: slug(stem),
+		}
+		i := len(ix.files)
+		ix.files = append(ix.files, f)
+		ix.byName[strings.ToLower(name)] = append(ix.byName[strings.ToLower(name)], i)
+		ix.byStem[strings.ToLower(stem)] = append(ix.byStem[strings.ToLower(stem)], i)
+		ix.byExt[f.ext] = append(ix.byExt[f.ext], i)
+		ix.filesInDirs[f.dir] = append(ix.filesInDirs[f.dir], i)
+		return nil
+	})
+}
+
+func allowedExt(ext string, exts []string) bool {
+	return len(exts) == 0 || hasExt(ext, exts)
+}
+
+// candidates scores the given files and ranks them best first.
+func (ix *sourceIndex) candidates(ids []int, score func(indexedFile) float64) []Candidate {
+	out := make([]Candidate, 0, len(ids))
+	for _, i := range ids {
+		out = append(out, Candidate{Path: ix.files[i].path, Score: score(ix.files[i])})
+	}
+	return rankCandidates(out)
+}
+
+// findBySlug looks in directories whose name matches dirSlugs for files matching fileSlugs.
+// Other files there with an allowed extension are kept as weaker candidates.
+func (ix *sourceIndex) findBySlug(dirSlugs []string, fileSlugs []string, exts []string) ([]Candidate, error) {
+	if len(dirSlugs) == 0 || len(fileSlugs) == 0 {
+		return nil, fmt.Errorf("missing slug")
+	}
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+
+	var out []Candidate
+	for _, d := range ix.dirs {
+		dirScore := slugScore(d.slug, dirSlugs)
+		if dirScore == 0 {
+			continue
+		}
+		for _, i := range ix.filesInDirs[d.path] {
+			f := ix.files[i]
+			if !allowedExt(f.ext, exts) {
+				continue
+			}
+			if fileScore := slugScore(f.slug, fileSlugs); fileScore > 0 {
+				out = append(out, Candidate{Path: f.path, Score: scoreSlugDir * dirScore * fileScore})
+			} else {
+				out = append(out, Candidate{Path: f.path, Score: scoreSlugDirOther * dirScore})
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no slug match")
+	}
+	return rankCandidates(out), nil
+}
+
+// findInMatchingDir returns files with an allowed extension in directories named dirName.
+func (ix *sourceIndex) findInMatchingDir(dirName string, exts []string) ([]Candidate, error) {
+	if dirName == "." || dirName == "" {
+		return nil, fmt.Errorf("no dir name provided")
+	}
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, di := range ix.dirsByName[strings.ToLower(dirName)] {
+		for _, i := range ix.filesInDirs[ix.dirs[di].path] {
+			if allowedExt(ix.files[i].ext, exts) {
+				ids = append(ids, i)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no match in dir %s", dirName)
+	}
+	return ix.candidates(ids, func(indexedFile) float64 { return scoreDirName }), nil
+}
+
+// findCaseInsensitive returns files named base, ignoring case.
+func (ix *sourceIndex) findCaseInsensitive(base string) ([]Candidate, error) {
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+	ids := ix.byName[strings.ToLower(base)]
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no case-insensitive match for %s", base)
+	}
+	return ix.candidates(ids, func(indexedFile) float64 { return scoreCaseInsensitive }), nil
+}
+
+// findCaseInsensitiveNoExt returns files named base with any extension, ignoring case.
+func (ix *sourceIndex) findCaseInsensitiveNoExt(base string) ([]Candidate, error) {
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+	ids := ix.byStem[strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))]
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no case-insensitive match (no ext) for %s", base)
+	}
+	return ix.candidates(ids, func(indexedFile) float64 { return scoreStem }), nil
+}
+
+// findAnyBySlug returns files anywhere whose name matches fileSlugs.
+func (ix *sourceIndex) findAnyBySlug(fileSlugs []string, exts []string) ([]Candidate, error) {
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+	var out []Candidate
+	for _, f := range ix.files {
+		if !allowedExt(f.ext, exts) {
+			continue
+		}
+		if score := slugScore(f.slug, fileSlugs); score > 0 {
+			out = append(out, Candidate{Path: f.path, Score: scoreSlugAnywhere * score})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no slug match anywhere")
+	}
+	return rankCandidates(out), nil
+}
+
+// findAnyByExt returns every file with an allowed extension. It is the last resort and says
+// nothing about whether the file belongs to the game.
+func (ix *sourceIndex) findAnyByExt(exts []string) ([]Candidate, error) {
+	if len(exts) == 0 {
+		return nil, fmt.Errorf("no extension provided")
+	}
+	if err := ix.load(); err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, ext := range exts {
+		ids = append(ids, ix.byExt[strings.ToLower(ext)]...)
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no files with allowed extensions")
+	}
+	return ix.candidates(ids, func(indexedFile) float64 { return scoreExtensionOnly }), nil
+}
@@ -0,0 +1,105 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSourceIndexLookups(t *testing.T) {
+	root := t.TempDir()
+	mustWrite(t, filepath.Join(root, "Games/Boulder Dash/Boulder_Dash.D64"), []byte("d"))
+	mustWrite(t, filepath.Join(root, "Games/Boulder Dash/notes.txt"), []byte("n"))
+	mustWrite(t, filepath.Join(root, "Carts/Paradroid.crt"), []byte("c"))
+
+	idx := newSourceIndex(context.Background(), root)
+
+	if got, err := idx.findBySlug([]string{"boulderdash"}, []string{"boulderdash"}, []string{"d64"}); err != nil || filepath.Base(got[0].Path) != "Boulder_Dash.D64" {
+		t.Fatalf("findBySlug = %v, %v", got, err)
+	}
+	if got, err := idx.findInMatchingDir("boulder dash", []string{"d64"}); err != nil || filepath.Base(got[0].Path) != "Boulder_Dash.D64" {
+		t.Fatalf("findInMatchingDir = %v, %v", got, err)
+	}
+	if got, err := idx.findCaseInsensitive("paradroid.CRT"); err != nil || filepath.Base(got[0].Path) != "Paradroid.crt" {
+		t.Fatalf("findCaseInsensitive = %v, %v", got, err)
+	}
+	if got, err := idx.findCaseInsensitiveNoExt("PARADROID.prg"); err != nil || filepath.Base(got[0].Path) != "Paradroid.crt" {
+		t.Fatalf("findCaseInsensitiveNoExt = %v, %v", got, err)
+	}
+	if _, err := idx.findAnyBySlug([]string{"zaxxon"}, []string{"d64"}); err == nil {
+		t.Fatalf("findAnyBySlug should not match")
+	}
+	if got, err := idx.findAnyByExt([]string{"crt"}); err != nil || filepath.Base(got[0].Path) != "Paradroid.crt" {
+		t.Fatalf("findAnyByExt = %v, %v", got, err)
+	}
+}
+
+// BenchmarkSourceLookup compares resolving every planned file against one shared index with
+// walking the input tree once per file, which is what Apply did before the index existed
+// (and up to six times per file when the early strategies missed). walkCaseInsensitiveNoExt
+// keeps that old lookup for the comparison.
+func BenchmarkSourceLookup(b *testing.B) {
+	root := b.TempDir()
+	const games = 500
+	var names []string
+	for i := 0; i < games; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("Game %03d", i))
+		for d := 1; d <= 3; d++ {
+			name := fmt.Sprintf("Game_%03d_Disk%d.d64", i, d)
+			mustWrite(b, filepath.Join(dir, name), []byte("disk"))
+		}
+		names = append(names, fmt.Sprintf("GAME_%03d_DISK1.prg", i))
+	}
+
+	b.Run("walk-per-file", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, name := range names {
+				if _, err := walkCaseInsensitiveNoExt(root, name); err != nil {
+					b.Fatalf("lookup %s: %v", name, err)
+				}
+			}
+		}
+	})
+	b.Run("shared-index", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			idx := newSourceIndex(context.Background(), root)
+			for _, name := range names {
+				if _, err := idx.findCaseInsensitiveNoExt(name); err != nil {
+					b.Fatalf("lookup %s: %v", name, err)
+				}
+			}
+		}
+	})
+}
+
+// walkCaseInsensitiveNoExt is the lookup Apply used before the index: one walk of the input
+// tree per call.
+func walkCaseInsensitiveNoExt(root, base string) (string, error) {
+	baseNoExt := strings.TrimSuffix(base, filepath.Ext(base))
+	var matches []string
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		nameNoExt := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+		if strings.EqualFold(nameNoExt, baseNoExt) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no case-insensitive match (no ext) for %s", base)
+	}
+	sort.Strings(matches)
+	return matches[0], nil
+}
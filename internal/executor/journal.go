@@ -0,0 +1,392 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournalDir is the folder at the output root holding the journal of an unfinished run and the
+// backups of the files it replaced or deleted.
+const JournalDir = ".c64dreams-journal"
+
+const (
+	journalFile = "journal.ndjson"
+	backupDir   = "backup"
+	tempSuffix  = ".c64dreams-tmp"
+)
+
+// ErrUnfinishedRun is returned when a journal from an earlier run is still present.
+var ErrUnfinishedRun = errors.New("output has an unfinished run; undo it with build --rollback or remove " + JournalDir)
+
+// Journal records every change a run makes under the output root before making it, so a failed
+// or interrupted run can be undone with Rollback. Replaced and deleted files are moved into the
+// journal's backup folder rather than lost. A nil *Journal records nothing. It is safe for
+// concurrent use.
+type Journal struct {
+	mu        sync.Mutex
+	outputAbs string
+	f         *os.File
+	entries   int
+	backups   int
+}
+
+type journalEntry struct {
+	Op     string `json:"op"` // create, replace, delete, mkdir, rmdir
+	Path   string `json:"path"`
+	Backup string `json:"backup,omitempty"`
+}
+
+// StartJournal begins the journal for a run writing under outputRoot.
+func StartJournal(outputRoot string) (*Journal, error) {
+	outputAbs, err := filepath.Abs(outputRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve output root: %w", err)
+	}
+	dir := filepath.Join(outputAbs, JournalDir)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, ErrUnfinishedRun
+	}
+	if err := os.MkdirAll(filepath.Join(dir, backupDir), 0o755); err != nil {
+		return nil, fmt.Errorf("create journal: %w", err)
+	}
+	f, err := os.Create(filepath.Join(dir, journalFile))
+	if err != nil {
+		return nil, fmt.Errorf("create journal: %w", err)
+	}
+	return &Journal{outputAbs: outputAbs, f: f}, nil
+}
+
+// Commit ends a run that finished cleanly, discarding the journal and its backups.
+func (j *Journal) Commit() error {
+	if j == nil {
+		return nil
+	}
+	if err := j.Close(); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(j.outputAbs, JournalDir))
+}
+
+// Empty reports whether the run has not changed anything yet.
+func (j *Journal) Empty() bool {
+	if j == nil {
+		return true
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.entries == 0
+}
+
+// Close ends a run that did not finish cleanly, keeping the journal for Rollback.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
+
+// add appends an entry and syncs it to disk before the change it describes is made.
+// The caller holds j.mu.
+func (j *Journal) add(op, dest, backup string) error {
+	rel, err := filepath.Rel(j.outputAbs, dest)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	line, err := json.Marshal(journalEntry{Op: op, Path: filepath.ToSlash(rel), Backup: backup})
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	if err := j.f.Sync(); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	j.entries++
+	return nil
+}
+
+// nextBackup returns a fresh backup name. The caller holds j.mu.
+func (j *Journal) nextBackup() string {
+	j.backups++
+	return strconv.Itoa(j.backups)
+}
+
+func (j *Journal) backupPath(name string) string {
+	return filepath.Join(j.outputAbs, JournalDir, backupDir, name)
+}
+
+// mkdirAll creates dir and its missing parents, journaling each one it creates.
+func (j *Journal) mkdirAll(dir string) error {
+	if j == nil {
+		return os.MkdirAll(dir, 0o755)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var missing []string
+	for d := dir; d != j.outputAbs && strings.HasPrefix(d, j.outputAbs); d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append(missing, d)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := j.add("mkdir", missing[i], ""); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(dir, 0o755)
+}
+
+// writeAtomic writes dest through a temporary file in the same directory that is synced and
+// then renamed into place, so an interrupted write never leaves a truncated dest behind. The
+// file it replaces, if any, is moved to the journal's backups.
+func (j *Journal) writeAtomic(dest string, write func(io.Writer) error) error {
+	_, statErr := os.Stat(dest)
+	replacing := statErr == nil
+	backup := ""
+	if j != nil {
+		j.mu.Lock()
+		op := "create"
+		if replacing {
+			op, backup = "replace", j.nextBackup()
+		}
+		err := j.add(op, dest, backup)
+		j.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	tmp := dest + tempSuffix
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("create dest: %w", err)
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("sync: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("close: %w", err)
+	}
+
+	if backup != "" {
+		if err := os.Rename(dest, j.backupPath(backup)); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("back up dest: %w", err)
+		}
+		if err := syncDir(filepath.Dir(j.backupPath(backup))); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("back up dest: %w", err)
+		}
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename into place: %w", err)
+	}
+	// the rename is only durable once the directory entry is on the card
+	if err := syncDir(filepath.Dir(dest)); err != nil {
+		return fmt.Errorf("rename into place: %w", err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory's entries to disk, so renames into and out of it survive a power
+// cut. Windows cannot sync directories; its renames are left to the filesystem.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("sync %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %w", dir, err)
+	}
+	return nil
+}
+
+// remove deletes a file, keeping it in the journal's backups.
+func (j *Journal) remove(dest string) error {
+	if j == nil {
+		return os.Remove(dest)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	backup := j.nextBackup()
+	if err := j.add("delete", dest, backup); err != nil {
+		return err
+	}
+	if err := os.Rename(dest, j.backupPath(backup)); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(j.backupPath(backup)))
+}
+
+// removeDir removes an empty directory.
+func (j *Journal) removeDir(dir string) error {
+	if j == nil {
+		return os.Remove(dir)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.add("rmdir", dir, ""); err != nil {
+		return err
+	}
+	return os.Remove(dir)
+}
+
+// Rollback undoes the unfinished run journaled under outputRoot, newest change first: created
+// files and folders are removed and replaced or deleted files are restored from their backups.
+// Results use Action "delete", "restore", "rmdir" and "mkdir"; in dry-run mode nothing changes.
+// The journal is removed once every change has been undone.
+func Rollback(outputRoot string, dryRun bool) ([]Result, error) {
+	outputAbs, err := filepath.Abs(outputRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve output root: %w", err)
+	}
+	dir := filepath.Join(outputAbs, JournalDir)
+	f, err := os.Open(filepath.Join(dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("no unfinished run to roll back")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e journalEntry
+		// a run killed mid-write can leave a partial last line; its change was never made
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			break
+		}
+		entries = append(entries, e)
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+
+	var results []Result
+	gone := make(map[string]struct{}) // files a dry run would have removed
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		target := filepath.Join(outputAbs, filepath.FromSlash(e.Path))
+		backup := filepath.Join(dir, backupDir, e.Backup)
+		res, err := undo(e, target, backup, dryRun, gone)
+		if res.Action != "" {
+			results = append(results, res)
+		}
+		if err != nil {
+			res.Action, res.Error = "error", fmt.Errorf("%w: undo %s %s: %w", ErrWrite, e.Op, e.Path, err)
+			return append(results, res), res.Error
+		}
+	}
+
+	if !dryRun {
+		if err := os.RemoveAll(dir); err != nil {
+			return results, fmt.Errorf("remove journal: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// undo reverts one journal entry. Changes that never happened because the run stopped between
+// journaling and acting are skipped. In dry-run mode gone collects what would have been removed.
+func undo(e journalEntry, target, backup string, dry bool, gone map[string]struct{}) (Result, error) {
+	exists := func(p string) bool {
+		_, err := os.Lstat(p)
+		return err == nil
+	}
+	switch e.Op {
+	case "create":
+		if dry {
+			if exists(target) {
+				gone[target] = struct{}{}
+				return Result{Dest: target, Action: "delete"}, nil
+			}
+			return Result{}, nil
+		}
+		os.Remove(target + tempSuffix)
+		if err := os.Remove(target); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return Result{}, nil
+			}
+			return Result{Dest: target}, err
+		}
+		return Result{Dest: target, Action: "delete"}, nil
+	case "replace", "delete":
+		if !exists(backup) {
+			if !dry {
+				os.Remove(target + tempSuffix)
+			}
+			return Result{}, nil
+		}
+		if dry {
+			return Result{Source: backup, Dest: target, Action: "restore"}, nil
+		}
+		os.Remove(target + tempSuffix)
+		if err := os.Rename(backup, target); err != nil {
+			return Result{Source: backup, Dest: target}, err
+		}
+		return Result{Source: backup, Dest: target, Action: "restore"}, nil
+	case "mkdir":
+		entries, err := os.ReadDir(target)
+		if err != nil {
+			return Result{}, nil
+		}
+		for _, entry := range entries {
+			if _, ok := gone[filepath.Join(target, entry.Name())]; !ok {
+				// holds files this run did not write
+				return Result{}, nil
+			}
+		}
+		if dry {
+			gone[target] = struct{}{}
+		} else {
+			if err := os.Remove(target); err != nil {
+				return Result{Dest: target}, err
+			}
+		}
+		return Result{Dest: target, Action: "rmdir"}, nil
+	case "rmdir":
+		if exists(target) {
+			return Result{}, nil
+		}
+		if !dry {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return Result{Dest: target}, err
+			}
+		}
+		return Result{Dest: target, Action: "mkdir"}, nil
+	default:
+		return Result{}, fmt.Errorf("unknown journal operation %q", e.Op)
+	}
+}
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// snapshot maps every file and folder under root, except the journal, to its content.
+func snapshot(t *testing.T, root string) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		if rel == JournalDir && d.IsDir() {
+			return filepath.SkipDir
+		}
+		if d.IsDir() {
+			out[rel+"/"] = ""
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		out[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	return out
+}
+
+func TestRollbackRestoresOutput(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "a/alpha.d64"), []byte("alpha"))
+	mustWrite(t, filepath.Join(src, "b/beta.d64"), []byte("beta"))
+	mustWrite(t, filepath.Join(src, "c/gamma.d64"), []byte("gamma"))
+
+	first := []layout.PlannedFile{
+		{GameID: "alpha", Path: "a/alpha.d64", Target: model.TargetSD2IEC},
+		{GameID: "gamma", Path: "old/gamma.d64", Source: "c/gamma.d64", Target: model.TargetSD2IEC},
+	}
+	if _, err := Apply(context.Background(), first, Options{InputRoot: src, OutputRoot: dst}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	mustWrite(t, filepath.Join(dst, "a/notes.txt"), []byte("mine"))
+	before := snapshot(t, dst)
+
+	// the next run replaces alpha, adds beta and prunes the old gamma copy
+	mustWrite(t, filepath.Join(src, "a/alpha.d64"), []byte("alpha v2"))
+	second := []layout.PlannedFile{
+		{GameID: "alpha", Path: "a/alpha.d64", Target: model.TargetSD2IEC},
+		{GameID: "beta", Path: "b/new/beta.d64", Source: "b/beta.d64", Target: model.TargetSD2IEC},
+	}
+	journal, err := StartJournal(dst)
+	if err != nil {
+		t.Fatalf("StartJournal: %v", err)
+	}
+	opts := Options{InputRoot: src, OutputRoot: dst, Journal: journal}
+	results, err := Apply(context.Background(), second, opts)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if _, err := Prune(context.Background(), results, opts); err != nil {
+		t.Fatalf("Prune returned error: %v", err)
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if reflect.DeepEqual(snapshot(t, dst), before) {
+		t.Fatalf("second run should have changed the output")
+	}
+	if _, err := StartJournal(dst); !errors.Is(err, ErrUnfinishedRun) {
+		t.Fatalf("expected ErrUnfinishedRun while a journal is pending, got %v", err)
+	}
+
+	planned, err := Rollback(dst, true)
+	if err != nil {
+		t.Fatalf("dry-run Rollback returned error: %v", err)
+	}
+	if len(planned) == 0 {
+		t.Fatalf("dry-run rollback should list the changes to undo")
+	}
+	if _, err := os.Stat(filepath.Join(dst, JournalDir)); err != nil {
+		t.Fatalf("dry-run rollback should keep the journal: %v", err)
+	}
+
+	undone, err := Rollback(dst, false)
+	if err != nil {
+		t.Fatalf("Rollback returned error: %v", err)
+	}
+	if !reflect.DeepEqual(actionsByDest(planned, dst), actionsByDest(undone, dst)) {
+		t.Fatalf("dry-run rollback %v differs from rollback %v", actionsByDest(planned, dst), actionsByDest(undone, dst))
+	}
+	if after := snapshot(t, dst); !reflect.DeepEqual(after, before) {
+		t.Fatalf("rollback did not restore the output:\n got %v\nwant %v", after, before)
+	}
+	if _, err := os.Stat(filepath.Join(dst, JournalDir)); !os.IsNotExist(err) {
+		t.Fatalf("rollback should remove the journal, stat err %v", err)
+	}
+}
+
+func TestWriteAtomicLeavesNoPartialFile(t *testing.T) {
+	dst := t.TempDir()
+	dest := filepath.Join(dst, "game.d64")
+	mustWrite(t, dest, []byte("old"))
+
+	var j *Journal
+	err := j.writeAtomic(dest, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("interrupted")
+	})
+	if err == nil {
+		t.Fatalf("expected the write error")
+	}
+	data, _ := os.ReadFile(dest)
+	if string(data) != "old" {
+		t.Fatalf("failed write should leave the old file, got %q", data)
+	}
+	entries, _ := os.ReadDir(dst)
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), tempSuffix) {
+			t.Fatalf("temporary file %s left behind", e.Name())
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ManifestName is the file at the output root that records what earlier runs wrote.
+const ManifestName = ".c64dreams-manifest.json"
+
+const manifestVersion = 1
+
+// Manifest records every file the executor wrote under an output root, keyed by its
+// output-relative slash path, so later runs only copy new or changed files.
+type Manifest struct {
+	Version int                      `json:"version"`
+	Files   map[string]ManifestEntry `json:"files"`
+}
+
+// ManifestEntry describes the source a destination was written from.
+type ManifestEntry struct {
+	Source  string    `json:"source,omitempty"` // Input-relative source path; empty for generated files
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	SHA256  string    `json:"sha256"`
+}
+
+// ReadManifest loads the manifest under an output root. A missing manifest is empty.
+func ReadManifest(outputRoot string) (*Manifest, error) {
+	m := &Manifest{Version: manifestVersion, Files: map[string]ManifestEntry{}}
+	data, err := os.ReadFile(filepath.Join(outputRoot, ManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d (expected %d)", m.Version, manifestVersion)
+	}
+	if m.Files == nil {
+		m.Files = map[string]ManifestEntry{}
+	}
+	return m, nil
+}
+
+// Write saves the manifest under an output root.
+func (m *Manifest) Write(outputRoot string) error {
+	return m.write(outputRoot, nil)
+}
+
+func (m *Manifest) write(outputRoot string, j *Journal) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	err = j.writeAtomic(filepath.Join(outputRoot, ManifestName), func(w io.Writer) error {
+		_, err := w.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// manifestState guards the manifest of one Apply run, which workers share.
+type manifestState struct {
+	mu        sync.Mutex
+	m         *Manifest
+	inputAbs  string
+	outputAbs string
+	journal   *Journal
+	changed   bool
+}
+
+func (s *manifestState) key(dest string) string {
+	rel, err := filepath.Rel(s.outputAbs, dest)
+	if err != nil {
+		return filepath.ToSlash(dest)
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (s *manifestState) source(src string) string {
+	if src == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return filepath.ToSlash(src)
+	}
+	rel, err := filepath.Rel(s.inputAbs, abs)
+	if err != nil {
+		return filepath.ToSlash(src)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// owned returns the manifest entry for dest if the file there is still the one a previous
+// run wrote, judged by its size.
+func (s *manifestState) owned(dest string, destInfo os.FileInfo) (ManifestEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.m.Files[s.key(dest)]
+	if !ok || destInfo.Size() != entry.Size {
+		return ManifestEntry{}, false
+	}
+	return entry, true
+}
+
+// unchanged reports whether dest already holds src as recorded. A source whose modification
+// time moved but whose content hashes the same counts as unchanged, and its entry is refreshed.
+func (s *manifestState) unchanged(src, dest string, srcInfo, destInfo os.FileInfo) bool {
+	entry, ok := s.owned(dest, destInfo)
+	if !ok || entry.Source != s.source(src) || entry.Size != srcInfo.Size() {
+		return false
+	}
+	if entry.ModTime.Equal(srcInfo.ModTime()) {
+		return true
+	}
+	sum, err := hashFile(src)
+	if err != nil || sum != entry.SHA256 {
+		return false
+	}
+	s.record(src, dest, srcInfo.Size(), srcInfo.ModTime(), sum)
+	return true
+}
+
+// record notes that dest now holds src (empty for generated content).
+func (s *manifestState) record(src, dest string, size int64, modTime time.Time, sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m.Files[s.key(dest)] = ManifestEntry{Source: s.source(src), Size: size, ModTime: modTime.UTC(), SHA256: sum}
+	s.changed = true
+}
+
+// save writes the manifest if this run recorded anything.
+func (s *manifestState) save() error {
+	if !s.changed {
+		return nil
+	}
+	return s.m.write(s.outputAbs, s.journal)
+}
+
+func hashFile(path string) (string, error) {
+	f, err := openSource(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func actionsByDest(results []Result, root string) map[string]string {
+	out := make(map[string]string)
+	for _, r := range results {
+		if r.Action == "mkdir" {
+			continue
+		}
+		rel, _ := filepath.Rel(root, r.Dest)
+		out[filepath.ToSlash(rel)] = r.Action
+	}
+	return out
+}
+
+func TestApplySyncCopiesOnlyNewOrChangedFiles(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "a/alpha.d64"), []byte("alpha"))
+	mustWrite(t, filepath.Join(src, "b/beta.d64"), []byte("beta"))
+	mustWrite(t, filepath.Join(src, "c/gamma.d64"), []byte("gamma"))
+	mustWrite(t, filepath.Join(dst, "c/gamma.d64"), []byte("not ours"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "alpha", Path: "a/alpha.d64", Target: model.TargetSD2IEC},
+		{GameID: "beta", Path: "b/beta.d64", Target: model.TargetSD2IEC},
+		{GameID: "gamma", Path: "c/gamma.d64", Target: model.TargetSD2IEC},
+		{GameID: "readme", Path: "readme.txt", Body: "hello\n", Target: model.TargetSD2IEC},
+	}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("first Apply returned error: %v", err)
+	}
+	want := map[string]string{"a/alpha.d64": "copy", "b/beta.d64": "copy", "c/gamma.d64": "skip", "readme.txt": "write"}
+	if got := actionsByDest(results, dst); !equalActions(got, want) {
+		t.Fatalf("first run actions = %v, want %v", got, want)
+	}
+
+	m, err := ReadManifest(dst)
+	if err != nil {
+		t.Fatalf("ReadManifest: %v", err)
+	}
+	entry, ok := m.Files["a/alpha.d64"]
+	if !ok || entry.Source != "a/alpha.d64" || entry.Size != 5 || entry.SHA256 == "" {
+		t.Fatalf("unexpected manifest entry %+v", entry)
+	}
+	if _, ok := m.Files["c/gamma.d64"]; ok {
+		t.Fatalf("files that were skipped should not be recorded")
+	}
+
+	// beta changes size, alpha only gets a new modification time
+	mustWrite(t, filepath.Join(src, "b/beta.d64"), []byte("beta v2"))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(src, "a/alpha.d64"), later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	results, err = Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("second Apply returned error: %v", err)
+	}
+	want = map[string]string{"a/alpha.d64": "unchanged", "b/beta.d64": "copy", "c/gamma.d64": "skip", "readme.txt": "unchanged"}
+	if got := actionsByDest(results, dst); !equalActions(got, want) {
+		t.Fatalf("second run actions = %v, want %v", got, want)
+	}
+	data, _ := os.ReadFile(filepath.Join(dst, "b/beta.d64"))
+	if string(data) != "beta v2" {
+		t.Fatalf("changed source should be copied again without --overwrite, got %q", data)
+	}
+
+	m, _ = ReadManifest(dst)
+	if !m.Files["a/alpha.d64"].ModTime.Equal(later) {
+		t.Fatalf("manifest should pick up the new modification time of an unchanged source")
+	}
+}
+
+func equalActions(got, want map[string]string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for k, v := range want {
+		if got[k] != v {
+			return false
+		}
+	}
+	return true
+}
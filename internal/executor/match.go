@@ -0,0 +1,178 @@
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Strategies record how a planned source was found in the input tree.
+const (
+	StrategyExact           = "exact"            // The planned source path exists
+	StrategySlugDir         = "slug-dir"         // A file in a folder whose name matches the game
+	StrategyDirName         = "dir-name"         // Any usable file in a folder named like the planned one
+	StrategyCaseInsensitive = "case-insensitive" // Same file name with different case
+	StrategyStem            = "stem"             // Same file name with a different extension
+	StrategySlugAnywhere    = "slug-anywhere"    // A file named like the game anywhere in the input
+	StrategyExtensionOnly   = "extension-only"   // Any file with a usable extension; almost certainly wrong
+)
+
+// maxCandidates is how many ranked candidates a match keeps; the rest are only counted.
+const maxCandidates = 5
+
+// ReviewConfidence is the confidence below which a match is reported for review and rejected by Strict.
+const ReviewConfidence = 0.75
+
+// Candidate scores, from 0 (no evidence) to 1 (the literal path).
+const (
+	scoreExact           = 1.0
+	scoreCaseInsensitive = 0.95
+	scoreSlugDir         = 0.9
+	scoreStem            = 0.8
+	scoreDirName         = 0.7
+	scoreSlugAnywhere    = 0.6
+	scoreSlugDirOther    = 0.4
+	scoreExtensionOnly   = 0.1
+
+	// slugs that only contain one another are weaker evidence than equal slugs
+	partialSlug = 0.7
+)
+
+// Candidate is a possible source file with its match score.
+type Candidate struct {
+	Path  string  `json:"path"`
+	Score float64 `json:"score"`
+}
+
+// sourceMatch is the outcome of resolving one planned source.
+type sourceMatch struct {
+	strategy   string
+	candidates []Candidate // best first, at most maxCandidates; the first one is used
+	more       int         // candidates ranked below those kept
+	ties       int         // candidates, kept or not, sharing the top score
+}
+
+// newSourceMatch keeps the best maxCandidates of the ranked candidates and counts the rest.
+func newSourceMatch(strategy string, ranked []Candidate) sourceMatch {
+	m := sourceMatch{strategy: strategy, candidates: ranked}
+	for _, c := range ranked {
+		if c.Score < ranked[0].Score {
+			break
+		}
+		m.ties++
+	}
+	if len(ranked) > maxCandidates {
+		m.candidates = ranked[:maxCandidates:maxCandidates]
+		m.more = len(ranked) - maxCandidates
+	}
+	return m
+}
+
+func (m sourceMatch) confidence() float64 {
+	if len(m.candidates) == 0 {
+		return 0
+	}
+	return m.candidates[0].Score
+}
+
+// ambiguous reports whether another candidate scored as well as the winner.
+func (m sourceMatch) ambiguous() bool {
+	return len(m.candidates) > 1 && m.candidates[1].Score >= m.candidates[0].Score
+}
+
+// stamp records the match on a result.
+func (m sourceMatch) stamp(r *Result) {
+	r.Strategy = m.strategy
+	r.Confidence = m.confidence()
+	if m.strategy != StrategyExact {
+		r.Candidates = m.candidates
+		r.MoreCandidates = m.more
+	}
+}
+
+// sourceQuery describes a planned source that is not at its literal path.
+type sourceQuery struct {
+	base      string   // File name of the planned source
+	plannedIn string   // Name of the planned destination folder
+	dirSlugs  []string // Slugs a containing folder may match
+	fileSlugs []string // Slugs the file name may match
+	anySlugs  []string // Slugs for matching anywhere in the tree
+	exts      []string // Extensions the content type allows
+}
+
+// resolve tries each strategy from most to least specific and returns the first that finds anything.
+func (ix *sourceIndex) resolve(q sourceQuery) (sourceMatch, error) {
+	steps := []struct {
+		strategy string
+		find     func() ([]Candidate, error)
+	}{
+		{StrategySlugDir, func() ([]Candidate, error) { return ix.findBySlug(q.dirSlugs, q.fileSlugs, q.exts) }},
+		{StrategyDirName, func() ([]Candidate, error) { return ix.findInMatchingDir(q.plannedIn, q.exts) }},
+		{StrategyCaseInsensitive, func() ([]Candidate, error) { return ix.findCaseInsensitive(q.base) }},
+		{StrategyStem, func() ([]Candidate, error) { return ix.findCaseInsensitiveNoExt(q.base) }},
+		{StrategySlugAnywhere, func() ([]Candidate, error) { return ix.findAnyBySlug(q.anySlugs, q.exts) }},
+		{StrategyExtensionOnly, func() ([]Candidate, error) { return ix.findAnyByExt(q.exts) }},
+	}
+
+	var lastErr error
+	for _, step := range steps {
+		found, err := step.find()
+		if err == nil && len(found) > 0 {
+			return newSourceMatch(step.strategy, found), nil
+		}
+		lastErr = err
+	}
+	return sourceMatch{}, lastErr
+}
+
+// strictError explains why Strict rejects a match, or returns nil when it is acceptable.
+func (m sourceMatch) strictError() error {
+	if m.ambiguous() {
+		return fmt.Errorf("ambiguous source: %d candidates scored %.2f via %s (%s, %s, ...)",
+			m.ties, m.confidence(), m.strategy, filepath.Base(m.candidates[0].Path), filepath.Base(m.candidates[1].Path))
+	}
+	if m.confidence() < ReviewConfidence {
+		return fmt.Errorf("low-confidence source %s: %.2f via %s", filepath.Base(m.candidates[0].Path), m.confidence(), m.strategy)
+	}
+	return nil
+}
+
+// rankCandidates orders candidates by score, then path, keeping the best score per path.
+func rankCandidates(c []Candidate) []Candidate {
+	best := make(map[string]float64, len(c))
+	for _, cand := range c {
+		if score, ok := best[cand.Path]; !ok || cand.Score > score {
+			best[cand.Path] = cand.Score
+		}
+	}
+	out := make([]Candidate, 0, len(best))
+	for p, score := range best {
+		out = append(out, Candidate{Path: p, Score: score})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// slugScore rates how well s matches any of the slugs: 1 when equal, partialSlug when one
+// contains the other, 0 otherwise.
+func slugScore(s string, arr []string) float64 {
+	score := 0.0
+	for _, a := range arr {
+		if a == "" || s == "" {
+			continue
+		}
+		if s == a {
+			return 1
+		}
+		if strings.Contains(s, a) || strings.Contains(a, s) {
+			score = partialSlug
+		}
+	}
+	return score
+}
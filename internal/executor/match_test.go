@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestApplyRecordsMatchStrategy(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Jumpman/jumpman.d64"), []byte("d"))
+	mustWrite(t, filepath.Join(src, "Other/random.d64"), []byte("r"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "jumpman", Title: "Jumpman", Source: "Jumpman/jumpman.d64", Path: "j/jumpman/jumpman.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+		{GameID: "zaxxon", Title: "Zaxxon", Source: "Zaxxon/zaxxon.d64", Path: "z/zaxxon/zaxxon.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+	}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	byDest := map[string]Result{}
+	for _, r := range results {
+		if r.Action == "copy" {
+			byDest[filepath.Base(r.Dest)] = r
+		}
+	}
+	if r := byDest["jumpman.d64"]; r.Strategy != StrategyExact || r.Confidence != 1 || r.NeedsReview() {
+		t.Fatalf("unexpected exact match: %+v", r)
+	}
+	fallback := byDest["zaxxon.d64"]
+	if fallback.Strategy != StrategyExtensionOnly || !fallback.NeedsReview() {
+		t.Fatalf("expected an extension-only match flagged for review: %+v", fallback)
+	}
+	if len(fallback.Candidates) != 2 {
+		t.Fatalf("expected both disks as candidates: %+v", fallback.Candidates)
+	}
+
+	if _, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst, DryRun: true, Strict: true}); err == nil {
+		t.Fatalf("strict mode should reject the extension-only match")
+	}
+}
+
+func TestSlugScorePrefersEqualSlugs(t *testing.T) {
+	if got := slugScore("boulderdash", []string{"boulderdash"}); got != 1 {
+		t.Fatalf("equal slugs scored %v", got)
+	}
+	if got := slugScore("boulderdash2", []string{"boulderdash"}); got != partialSlug {
+		t.Fatalf("containing slug scored %v", got)
+	}
+	if got := slugScore("", []string{"boulderdash"}); got != 0 {
+		t.Fatalf("empty slug scored %v", got)
+	}
+}
+
+func TestApplyKeepsTopCandidates(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	for i := 0; i < 8; i++ {
+		mustWrite(t, filepath.Join(src, "Other", fmt.Sprintf("random%d.d64", i)), []byte("r"))
+	}
+	planned := []layout.PlannedFile{
+		{GameID: "zaxxon", Title: "Zaxxon", Source: "Zaxxon/zaxxon.d64", Path: "z/zaxxon/zaxxon.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+	}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: filepath.Join(root, "output"), DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	var r Result
+	for _, res := range results {
+		if res.Action == "copy" {
+			r = res
+		}
+	}
+	if r.Strategy != StrategyExtensionOnly || len(r.Candidates) != maxCandidates || r.MoreCandidates != 3 {
+		t.Fatalf("expected %d candidates and 3 more: %+v", maxCandidates, r)
+	}
+
+	_, err = Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: filepath.Join(root, "output"), DryRun: true, Strict: true})
+	if err == nil || !strings.Contains(err.Error(), "8 candidates") {
+		t.Fatalf("strict error should count every tied candidate: %v", err)
+	}
+}
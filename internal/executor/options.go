@@ -7,4 +7,12 @@ type Options struct {
 	DryRun     bool
 	Overwrite  bool
 	VerifyOnly bool
+	Strict     bool     // Fail on ambiguous sources or ones found with less than ReviewConfidence
+	KeepGoing  bool     // Record failed files and carry on; Apply then returns a *Failures
+	Jobs       int      // Planned files applied concurrently; 0 or 1 applies them one at a time
+	Journal    *Journal // Records the run's changes for Rollback; nil records nothing
+
+	// Progress, when set, receives an Event as files start and finish and as bytes are copied.
+	// Calls are never concurrent, even with several Jobs, and should return quickly.
+	Progress func(Event)
 }
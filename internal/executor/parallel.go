@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// plannedOutcome is what applying one planned file produced.
+type plannedOutcome struct {
+	results []Result
+	err     error
+	done    bool
+}
+
+// applyParallel applies n sorted planned files with opts.Jobs workers, each taking one group of
+// destinationGroups at a time, and assembles the results in plan order, so output and error
+// reporting match a sequential run. Without KeepGoing it returns the first failure in plan
+// order: files before it still finish, and workers start no file after it once it is known.
+// Files after it that were already under way when it failed are finished and returned too,
+// since they were written. Directory creation needs no coordination because os.MkdirAll
+// tolerates directories created concurrently. Once ctx is cancelled no new files start, and
+// the files that finished are returned in plan order.
+func applyParallel(ctx context.Context, groups [][]int, n int, opts Options, apply func(int) ([]Result, error)) ([]Result, error) {
+	outcomes := make([]plannedOutcome, n)
+
+	var mu sync.Mutex
+	firstFailure := n
+	failedBefore := func(i int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstFailure < i
+	}
+	fail := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if i < firstFailure {
+			firstFailure = i
+		}
+	}
+
+	work := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range work {
+				for _, i := range group {
+					if ctx.Err() != nil || (!opts.KeepGoing && failedBefore(i)) {
+						break
+					}
+					res, err := apply(i)
+					outcomes[i] = plannedOutcome{results: res, err: err, done: true}
+					if err != nil {
+						fail(i)
+					}
+				}
+			}
+		}()
+	}
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	wg.Wait()
+
+	results := make([]Result, 0, n)
+	var firstErr error
+	for _, o := range outcomes {
+		if !o.done {
+			continue
+		}
+		results = append(results, o.results...)
+		if firstErr == nil {
+			firstErr = o.err
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		return results, cancelled(ctx)
+	case opts.KeepGoing:
+		return results, CollectFailures(results)
+	default:
+		return results, firstErr
+	}
+}
+
+// destinationGroups splits planned files into groups that write disjoint destinations, in order
+// of first appearance. Variants sharing a folder copy the same siblings, so one worker handles
+// each group in plan order and no two workers ever write the same file. Destinations are
+// compared ignoring case because FAT cards do.
+func destinationGroups(plans []plannedOps) [][]int {
+	// union planned files through the destinations they write
+	parent := make([]int, len(plans))
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	writtenBy := make(map[string]int)
+	for i, po := range plans {
+		parent[i] = i
+		for _, op := range po.ops {
+			if op.kind != opCopy && op.kind != opWrite {
+				continue
+			}
+			key := strings.ToLower(op.res.Dest)
+			if j, ok := writtenBy[key]; ok {
+				parent[find(i)] = find(j)
+				continue
+			}
+			writtenBy[key] = i
+		}
+	}
+
+	byRoot := make(map[int]int)
+	var groups [][]int
+	for i := range plans {
+		root := find(i)
+		g, ok := byRoot[root]
+		if !ok {
+			g = len(groups)
+			byRoot[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// parallelFixture writes games whose sources are found literally, by fuzzy lookup and as shared
+// siblings of two variants, and returns the input root and plan.
+func parallelFixture(t *testing.T) (string, []layout.PlannedFile) {
+	t.Helper()
+	src := filepath.Join(t.TempDir(), "input")
+
+	var planned []layout.PlannedFile
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("game %02d", i)
+		dir := fmt.Sprintf("%c/%s", 'a'+i%5, name)
+		switch i % 3 {
+		case 0: // literal source
+			mustWrite(t, filepath.Join(src, dir, name+".d64"), []byte(name))
+		case 1: // found through the index
+			mustWrite(t, filepath.Join(src, "Games", strings.ToUpper(name), name+".d64"), []byte(name))
+		case 2: // two variants sharing a folder and a companion file
+			mustWrite(t, filepath.Join(src, dir, name+".d64"), []byte(name))
+			mustWrite(t, filepath.Join(src, dir, name+".prg"), []byte(name+" prg"))
+			mustWrite(t, filepath.Join(src, dir, "extra.d64"), []byte("extra"))
+			planned = append(planned, layout.PlannedFile{
+				GameID: name, VariantID: name + "-prg", Title: name, Path: dir + "/" + name + ".prg",
+				Target: model.TargetSD2IEC, Content: model.ContentPrg,
+			})
+		}
+		planned = append(planned, layout.PlannedFile{
+			GameID: name, VariantID: name, Title: name, Path: dir + "/" + name + ".d64",
+			Target: model.TargetSD2IEC, Content: model.ContentDisk,
+		})
+	}
+	return src, planned
+}
+
+// relResults strips the output root from destinations so runs into different roots compare equal.
+func relResults(results []Result, root string) []Result {
+	out := make([]Result, len(results))
+	for i, r := range results {
+		r.Dest = strings.TrimPrefix(r.Dest, root)
+		r.Error = nil
+		out[i] = r
+	}
+	return out
+}
+
+func TestApplyParallelMatchesSequential(t *testing.T) {
+	src, planned := parallelFixture(t)
+	root := t.TempDir()
+	seqDst, parDst := filepath.Join(root, "seq"), filepath.Join(root, "par")
+
+	seq, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: seqDst})
+	if err != nil {
+		t.Fatalf("sequential Apply returned error: %v", err)
+	}
+	par, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: parDst, Jobs: 8})
+	if err != nil {
+		t.Fatalf("parallel Apply returned error: %v", err)
+	}
+	if !reflect.DeepEqual(relResults(seq, seqDst), relResults(par, parDst)) {
+		t.Fatalf("parallel results differ from sequential:\n%+v\n%+v", relResults(seq, seqDst), relResults(par, parDst))
+	}
+
+	for _, r := range seq {
+		if r.Action != "copy" {
+			continue
+		}
+		rel := strings.TrimPrefix(r.Dest, seqDst)
+		want, _ := os.ReadFile(r.Dest)
+		got, err := os.ReadFile(filepath.Join(parDst, rel))
+		if err != nil || string(got) != string(want) {
+			t.Fatalf("parallel copy of %s = %q, %v; want %q", rel, got, err, want)
+		}
+	}
+}
+
+func TestApplyParallelStopsLikeSequential(t *testing.T) {
+	src, planned := parallelFixture(t)
+	planned = append(planned, layout.PlannedFile{
+		GameID: "missing", VariantID: "missing", Path: "b/missing/missing.t64",
+		Target: model.TargetUltimate, Content: model.ContentTape,
+	})
+	root := t.TempDir()
+
+	for _, dry := range []bool{true, false} {
+		for _, keepGoing := range []bool{false, true} {
+			seqDst := filepath.Join(root, fmt.Sprintf("seq-%v-%v", dry, keepGoing))
+			parDst := filepath.Join(root, fmt.Sprintf("par-%v-%v", dry, keepGoing))
+			seq, seqErr := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: seqDst, DryRun: dry, KeepGoing: keepGoing})
+			par, parErr := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: parDst, DryRun: dry, KeepGoing: keepGoing, Jobs: 4})
+			if seqErr == nil || parErr == nil {
+				t.Fatalf("dry=%v keepGoing=%v: expected errors, got %v and %v", dry, keepGoing, seqErr, parErr)
+			}
+			if Category(seqErr) != Category(parErr) {
+				t.Fatalf("dry=%v keepGoing=%v: parallel error differs from sequential: %v vs %v", dry, keepGoing, seqErr, parErr)
+			}
+
+			seqRel, parRel := relResults(seq, seqDst), relResults(par, parDst)
+			if keepGoing && !reflect.DeepEqual(seqRel, parRel) {
+				t.Fatalf("dry=%v: parallel results differ from sequential:\n%+v\n%+v", dry, seqRel, parRel)
+			}
+			// files after the failure that were already under way are reported as well
+			if !keepGoing && (len(parRel) < len(seqRel) || !reflect.DeepEqual(seqRel, parRel[:len(seqRel)])) {
+				t.Fatalf("dry=%v: parallel results should start with the sequential ones:\n%+v\n%+v", dry, seqRel, parRel)
+			}
+
+			if !dry {
+				// every file written is reported
+				reported := make(map[string]bool)
+				for _, r := range par {
+					if r.Action == "copy" {
+						reported[r.Dest] = true
+					}
+				}
+				err := filepath.WalkDir(parDst, func(p string, d os.DirEntry, err error) error {
+					if err != nil || d.IsDir() || d.Name() == ManifestName {
+						return err
+					}
+					if !reported[p] {
+						t.Errorf("keepGoing=%v: %s was written but not reported", keepGoing, p)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("walk output: %v", err)
+				}
+			}
+		}
+	}
+}
+
+func TestApplyParallelReturnsFilesFinishedAfterFailure(t *testing.T) {
+	// file 1 finishes before file 0 fails, as a worker may when it is already under way
+	finished := make(chan struct{})
+	apply := func(i int) ([]Result, error) {
+		if i == 0 {
+			<-finished
+			return []Result{{Dest: "a", Action: "error"}}, ErrWrite
+		}
+		defer close(finished)
+		return []Result{{Dest: "b", Action: "copy"}}, nil
+	}
+
+	results, err := applyParallel(context.Background(), [][]int{{0}, {1}}, 2, Options{Jobs: 2}, apply)
+	if !errors.Is(err, ErrWrite) {
+		t.Fatalf("expected the first failure, got %v", err)
+	}
+	if len(results) != 2 || results[0].Dest != "a" || results[1].Dest != "b" {
+		t.Fatalf("expected both files in plan order: %+v", results)
+	}
+}
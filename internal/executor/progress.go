@@ -0,0 +1,89 @@
+package executor
+
+import "sync"
+
+// Progress event kinds.
+const (
+	EventStarted      = "started"       // Apply begins; totals are set
+	EventFileStarted  = "file-started"  // A planned file begins; Path is its planned destination
+	EventFileFinished = "file-finished" // A planned file and its siblings are done; Action is its own result
+	EventBytes        = "bytes"         // More bytes were copied; sent at most every progressBytesStep
+	EventFinished     = "finished"      // Apply is done
+)
+
+// progressBytesStep is how many copied bytes a bytes event reports at least, so large builds
+// don't flood the callback.
+const progressBytesStep = 1 << 20
+
+// Event reports how far an Apply run has come. Counters are cumulative for the run.
+type Event struct {
+	Kind       string `json:"kind"`
+	Path       string `json:"path,omitempty"`   // Output-relative planned path, for file events
+	Action     string `json:"action,omitempty"` // Outcome of the planned file, for file-finished
+	FilesDone  int    `json:"filesDone"`
+	FilesTotal int    `json:"filesTotal"` // Planned files in this run
+	BytesDone  int64  `json:"bytesDone"`  // Bytes copied or written so far
+	BytesTotal int64  `json:"bytesTotal"` // Sum of planned sizes, less files found in place or not copied; zero when the plan has no sizes (not resolved)
+}
+
+// progress tracks a run's counters and serializes calls to the callback, which is therefore
+// never called concurrently even with several jobs. A nil *progress reports nothing.
+type progress struct {
+	mu       sync.Mutex
+	fn       func(Event)
+	ev       Event
+	reported int64 // BytesDone at the last bytes event
+}
+
+func newProgress(fn func(Event), files int, bytes int64) *progress {
+	if fn == nil {
+		return nil
+	}
+	return &progress{fn: fn, ev: Event{FilesTotal: files, BytesTotal: bytes}}
+}
+
+func (p *progress) emit(kind, path, action string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ev := p.ev
+	ev.Kind, ev.Path, ev.Action = kind, path, action
+	p.fn(ev)
+}
+
+// finished counts a planned file as done. Its planned size is taken off the byte total unless
+// it was copied or written, so files already in place or skipped don't hold the total out of
+// reach.
+func (p *progress) finished(path, action string, size int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ev.FilesDone++
+	if action != "copy" && action != "write" {
+		p.ev.BytesTotal -= size
+	}
+	ev := p.ev
+	ev.Kind, ev.Path, ev.Action = EventFileFinished, path, action
+	p.fn(ev)
+}
+
+// copied counts bytes written and sends a bytes event once enough have accumulated.
+func (p *progress) copied(n int64) {
+	if p == nil || n == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ev.BytesDone += n
+	if p.ev.BytesDone-p.reported < progressBytesStep {
+		return
+	}
+	p.reported = p.ev.BytesDone
+	ev := p.ev
+	ev.Kind = EventBytes
+	p.fn(ev)
+}
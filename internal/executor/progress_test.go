@@ -0,0 +1,90 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestApplyReportsProgress(t *testing.T) {
+	src, planned := parallelFixture(t)
+	big := bytes.Repeat([]byte("x"), 3*progressBytesStep)
+	mustWrite(t, filepath.Join(src, "z/big/big.d64"), big)
+	planned = append(planned, layout.PlannedFile{
+		GameID: "big", VariantID: "big", Path: "z/big/big.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk,
+	})
+	dst := filepath.Join(t.TempDir(), "output")
+
+	var events []Event
+	opts := Options{InputRoot: src, OutputRoot: dst, Jobs: 4, Progress: func(ev Event) { events = append(events, ev) }}
+	results, err := Apply(context.Background(), planned, opts)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	if len(events) < 2 || events[0].Kind != EventStarted || events[len(events)-1].Kind != EventFinished {
+		t.Fatalf("events should open with started and close with finished, got %+v", events)
+	}
+	if events[0].FilesTotal != len(planned) {
+		t.Fatalf("FilesTotal = %d, want %d", events[0].FilesTotal, len(planned))
+	}
+
+	var copied int64
+	for _, r := range results {
+		if r.Action == "copy" {
+			info, _ := os.Stat(r.Dest)
+			copied += info.Size()
+		}
+	}
+	kinds := map[string]int{}
+	var lastBytes, lastFiles int64
+	for _, ev := range events {
+		kinds[ev.Kind]++
+		if ev.BytesDone < lastBytes || int64(ev.FilesDone) < lastFiles {
+			t.Fatalf("counters went backwards at %+v", ev)
+		}
+		lastBytes, lastFiles = ev.BytesDone, int64(ev.FilesDone)
+	}
+	if kinds[EventFileStarted] != len(planned) || kinds[EventFileFinished] != len(planned) {
+		t.Fatalf("expected one start and finish per planned file, got %v", kinds)
+	}
+	if kinds[EventBytes] < 2 {
+		t.Fatalf("copying %d bytes should report bytes events, got %v", len(big), kinds)
+	}
+	final := events[len(events)-1]
+	if final.FilesDone != len(planned) || final.BytesDone != copied {
+		t.Fatalf("final event %+v, want %d files and %d bytes", final, len(planned), copied)
+	}
+}
+
+func TestApplyProgressLeavesOutFilesInPlace(t *testing.T) {
+	src, planned := parallelFixture(t)
+	dst := filepath.Join(t.TempDir(), "output")
+	resolved, _, err := Resolve(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if _, err := Apply(context.Background(), resolved, Options{InputRoot: src, OutputRoot: dst}); err != nil {
+		t.Fatalf("first Apply returned error: %v", err)
+	}
+
+	var final Event
+	opts := Options{InputRoot: src, OutputRoot: dst, Progress: func(ev Event) { final = ev }}
+	results, err := Apply(context.Background(), resolved, opts)
+	if err != nil {
+		t.Fatalf("second Apply returned error: %v", err)
+	}
+	for _, r := range results {
+		if r.Action != "unchanged" && r.Action != "mkdir" {
+			t.Fatalf("expected every file unchanged, got %+v", r)
+		}
+	}
+	if final.BytesDone != 0 || final.BytesTotal != 0 {
+		t.Fatalf("files already in place should leave the byte total, got %+v", final)
+	}
+}
@@ -0,0 +1,143 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prune removes files an earlier run wrote under the output root that the given results no
+// longer place there, then removes the directories that leaves empty. Only files recorded in
+// the manifest are candidates, and a recorded file that was changed since it was written is
+// left alone. Results use Action "delete" and "rmdir"; in dry-run mode nothing is removed.
+// Cancelling ctx stops before the next file; what was removed so far is recorded.
+func Prune(ctx context.Context, results []Result, opts Options) ([]Result, error) {
+	if opts.OutputRoot == "" {
+		return nil, errors.New("output root is required")
+	}
+	dry := opts.DryRun || opts.VerifyOnly
+
+	outputAbs, err := filepath.Abs(opts.OutputRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve output root: %w", err)
+	}
+	m, err := ReadManifest(outputAbs)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := make(map[string]struct{}, len(results))
+	for _, r := range results {
+		if r.Action != "mkdir" && r.Action != "unsupported" {
+			keep[r.Dest] = struct{}{}
+		}
+	}
+
+	var stale []string
+	for rel := range m.Files {
+		dest := filepath.Join(outputAbs, filepath.FromSlash(rel))
+		if _, ok := keep[dest]; !ok {
+			stale = append(stale, rel)
+		}
+	}
+	sort.Strings(stale)
+
+	var out []Result
+	var failed error
+	removed := make(map[string]struct{})
+	dirs := make(map[string]struct{})
+	for _, rel := range stale {
+		if ctx.Err() != nil {
+			failed = cancelled(ctx)
+			break
+		}
+		entry := m.Files[rel]
+		dest := filepath.Join(outputAbs, filepath.FromSlash(rel))
+		info, err := os.Stat(dest)
+		if errors.Is(err, os.ErrNotExist) {
+			delete(m.Files, rel)
+			continue
+		}
+		if err != nil || info.IsDir() || info.Size() != entry.Size {
+			// no longer the file we wrote; forget it rather than delete someone else's file
+			delete(m.Files, rel)
+			out = append(out, Result{Dest: dest, Action: "skip"})
+			continue
+		}
+		if !dry {
+			if err := opts.Journal.remove(dest); err != nil {
+				res := Result{Dest: dest, Action: "error", Error: fmt.Errorf("%w: delete: %w", ErrWrite, err)}
+				out = append(out, res)
+				if !opts.KeepGoing {
+					failed = res.Error
+					break
+				}
+				continue
+			}
+		}
+		delete(m.Files, rel)
+		removed[dest] = struct{}{}
+		out = append(out, Result{Dest: dest, Action: "delete"})
+		for dir := filepath.Dir(dest); dir != outputAbs && strings.HasPrefix(dir, outputAbs); dir = filepath.Dir(dir) {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	out = append(out, removeEmptyDirs(dirs, removed, dry, opts.Journal)...)
+
+	if !dry {
+		if err := m.write(outputAbs, opts.Journal); err != nil {
+			return out, errors.Join(failed, err)
+		}
+	}
+	if failed == nil && opts.KeepGoing {
+		failed = CollectFailures(out)
+	}
+	return out, failed
+}
+
+// removeEmptyDirs removes, deepest first, the directories whose entries have all been removed.
+// In dry-run mode removed files are still present, so emptiness is judged against removed.
+func removeEmptyDirs(dirs, removed map[string]struct{}, dry bool, j *Journal) []Result {
+	ordered := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		ordered = append(ordered, dir)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		di, dj := strings.Count(ordered[i], string(filepath.Separator)), strings.Count(ordered[j], string(filepath.Separator))
+		if di != dj {
+			return di > dj
+		}
+		return ordered[i] < ordered[j]
+	})
+
+	var out []Result
+	for _, dir := range ordered {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		empty := true
+		for _, e := range entries {
+			if _, ok := removed[filepath.Join(dir, e.Name())]; !ok {
+				empty = false
+				break
+			}
+		}
+		if !empty {
+			continue
+		}
+		if !dry {
+			if err := j.removeDir(dir); err != nil {
+				continue
+			}
+		}
+		removed[dir] = struct{}{}
+		out = append(out, Result{Dest: dir, Action: "rmdir"})
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestPruneRemovesStaleManifestFiles(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "a/alpha.d64"), []byte("alpha"))
+	mustWrite(t, filepath.Join(src, "b/beta.d64"), []byte("beta"))
+	mustWrite(t, filepath.Join(src, "c/gamma.d64"), []byte("gamma"))
+
+	first := []layout.PlannedFile{
+		{GameID: "alpha", Path: "a/alpha/alpha.d64", Source: "a/alpha.d64", Target: model.TargetSD2IEC},
+		{GameID: "beta", Path: "b/beta/beta.d64", Source: "b/beta.d64", Target: model.TargetSD2IEC},
+		{GameID: "gamma", Path: "g/gamma/gamma.d64", Source: "c/gamma.d64", Target: model.TargetSD2IEC},
+	}
+	if _, err := Apply(context.Background(), first, Options{InputRoot: src, OutputRoot: dst}); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	mustWrite(t, filepath.Join(dst, "b/beta/notes.txt"), []byte("mine"))
+	mustWrite(t, filepath.Join(dst, "g/gamma/gamma.d64"), []byte("edited on the card"))
+
+	// beta and gamma are gone upstream; alpha moved
+	second := []layout.PlannedFile{
+		{GameID: "alpha", Path: "x/alpha/alpha.d64", Source: "a/alpha.d64", Target: model.TargetSD2IEC},
+	}
+	results, err := Apply(context.Background(), second, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	dryRun, err := Prune(context.Background(), results, Options{OutputRoot: dst, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry-run Prune returned error: %v", err)
+	}
+	want := map[string]string{
+		"a/alpha/alpha.d64": "delete", "a/alpha": "rmdir", "a": "rmdir",
+		"b/beta/beta.d64":   "delete",
+		"g/gamma/gamma.d64": "skip",
+	}
+	if got := actionsByDest(dryRun, dst); !equalActions(got, want) {
+		t.Fatalf("dry-run prune actions = %v, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a/alpha/alpha.d64")); err != nil {
+		t.Fatalf("dry-run prune should not delete: %v", err)
+	}
+
+	pruned, err := Prune(context.Background(), results, Options{OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("Prune returned error: %v", err)
+	}
+	if got := actionsByDest(pruned, dst); !equalActions(got, want) {
+		t.Fatalf("prune actions = %v, want %v", got, want)
+	}
+	for _, rel := range []string{"a", "b/beta/beta.d64"} {
+		if _, err := os.Stat(filepath.Join(dst, rel)); !os.IsNotExist(err) {
+			t.Fatalf("%s should be removed, stat err %v", rel, err)
+		}
+	}
+	for _, rel := range []string{"x/alpha/alpha.d64", "b/beta/notes.txt", "g/gamma/gamma.d64"} {
+		if _, err := os.Stat(filepath.Join(dst, rel)); err != nil {
+			t.Fatalf("%s should be kept: %v", rel, err)
+		}
+	}
+
+	m, err := ReadManifest(dst)
+	if err != nil {
+		t.Fatalf("ReadManifest: %v", err)
+	}
+	if len(m.Files) != 1 {
+		t.Fatalf("manifest should only list the current file, got %v", m.Files)
+	}
+}
@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+// Resolve turns a plan into concrete file operations without writing anything. Each file the
+// plan would copy, including siblings and files found inside source directories, becomes its own
+// entry with Resolved set, an input-relative Source, the exact destination Path and the source
+// Size. Generated files are kept as they are. Files the target cannot open are left out; the
+// returned results list them with Action "unsupported" alongside the dry-run actions.
+// In keep-going mode the files that resolved are returned together with a *Failures.
+func Resolve(ctx context.Context, planned []layout.PlannedFile, opts Options) ([]layout.PlannedFile, []Result, error) {
+	if opts.InputRoot == "" {
+		return nil, nil, errors.New("input root is required")
+	}
+	if opts.OutputRoot == "" {
+		return nil, nil, errors.New("output root is required")
+	}
+	inputAbs, err := filepath.Abs(opts.InputRoot)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve input root: %w", err)
+	}
+
+	// nothing is written; the output root only anchors the relative destinations
+	dryOpts := Options{InputRoot: inputAbs, OutputRoot: opts.OutputRoot, DryRun: true, Overwrite: true, Strict: opts.Strict, KeepGoing: opts.KeepGoing, Jobs: opts.Jobs}
+	results, applyErr := Apply(ctx, planned, dryOpts)
+	var failed *Failures
+	if applyErr != nil && !errors.As(applyErr, &failed) {
+		return nil, results, applyErr
+	}
+	outputAbs, err := filepath.Abs(opts.OutputRoot)
+	if err != nil {
+		return nil, results, fmt.Errorf("resolve output root: %w", err)
+	}
+
+	byVariant := make(map[string]layout.PlannedFile, len(planned))
+	for _, p := range planned {
+		byVariant[p.VariantID] = p
+	}
+
+	var resolved []layout.PlannedFile
+	seen := make(map[string]struct{})
+	for _, r := range results {
+		if r.Action != "copy" && r.Action != "write" && r.Action != "unchanged" {
+			continue
+		}
+		// variants sharing a folder pick up the same siblings; the first one claims them
+		if _, dup := seen[r.Dest]; dup {
+			continue
+		}
+		seen[r.Dest] = struct{}{}
+
+		p := byVariant[r.VariantID]
+		dest, err := filepath.Rel(outputAbs, r.Dest)
+		if err != nil {
+			return nil, results, fmt.Errorf("relative destination: %w", err)
+		}
+		p.Path = filepath.ToSlash(dest)
+		p.GameDir = ""
+		if r.Action == "write" || (r.Action == "unchanged" && r.Source == "") {
+			resolved = append(resolved, p)
+			continue
+		}
+
+		src, err := filepath.Rel(inputAbs, r.Source)
+		if err != nil {
+			return nil, results, fmt.Errorf("relative source: %w", err)
+		}
+		info, err := statSource(r.Source)
+		if err != nil {
+			return nil, results, fmt.Errorf("stat source: %w", err)
+		}
+		p.Source = filepath.ToSlash(src)
+		p.Size = info.Size()
+		p.Resolved = true
+		p.Extract = false
+		resolved = append(resolved, p)
+	}
+	return resolved, results, applyErr
+}
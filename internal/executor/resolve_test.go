@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestResolveExpandsSiblingsWithSizes(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Ultima/Ultima 1.d64"), []byte("disk1"))
+	mustWrite(t, filepath.Join(src, "Ultima/Ultima 2.d64"), []byte("disk22"))
+	mustWrite(t, filepath.Join(src, "Ultima/ultima.crt"), []byte("cart"))
+
+	planned := []layout.PlannedFile{
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/Ultima 1.d64", Path: "u/ultima/disk1.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+		{GameID: "ultima", VariantID: "ultima-1", Source: "Ultima/Ultima 2.d64", Path: "u/ultima/disk2.d64", Target: model.TargetSD2IEC, Content: model.ContentDisk},
+	}
+
+	resolved, _, err := Resolve(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+
+	got := map[string]layout.PlannedFile{}
+	for _, p := range resolved {
+		if !p.Resolved {
+			t.Fatalf("expected resolved entry: %+v", p)
+		}
+		if _, dup := got[p.Path]; dup {
+			t.Fatalf("destination %s resolved twice", p.Path)
+		}
+		got[p.Path] = p
+	}
+	// the cart is unsupported on sd2iec and each disk is copied once, under its planned name
+	if len(got) != 2 {
+		t.Fatalf("expected 2 resolved files, got %v", got)
+	}
+	if p := got["u/ultima/disk1.d64"]; p.Source != "Ultima/Ultima 1.d64" || p.Size != 5 {
+		t.Fatalf("unexpected primary: %+v", p)
+	}
+	if _, err := os.Stat(dst); err == nil {
+		t.Fatalf("Resolve should not create output")
+	}
+}
+
+func TestApplyResolvedRejectsChangedSource(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "game/game.prg"), []byte("changed"))
+
+	planned := []layout.PlannedFile{{
+		GameID: "g1", Source: "game/game.prg", Path: "g/game.prg", Target: model.TargetUltimate, Resolved: true, Size: 3,
+	}}
+
+	if _, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst}); err == nil {
+		t.Fatalf("expected error for a source that changed since planning")
+	}
+}
+
+func TestApplyResolvedLeavesOutUnsupportedSource(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "game/game.tap"), []byte("tape"))
+
+	planned := []layout.PlannedFile{{
+		GameID: "g1", Source: "game/game.tap", Path: "g/game.tap", Target: model.TargetKungFuFlash, Content: model.ContentTape, Resolved: true, Size: 4,
+	}}
+
+	results, err := Apply(context.Background(), planned, Options{InputRoot: src, OutputRoot: dst})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if got := actionsByDest(results, dst); got["g/game.tap"] != "unsupported" {
+		t.Fatalf("a source the target cannot open should be unsupported, got %v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "g", "game.tap")); err == nil {
+		t.Fatalf("unsupported source should not be copied")
+	}
+}
@@ -14,11 +14,50 @@ import (
 
 var wordCharRegexp = regexp.MustCompile(`[A-Za-z0-9]+`)
 
+// metadataColumns maps the metadata fields a layout can read (see layout.Options.MetadataFields)
+// to the sheet columns they come from. The C64 Dreams sheet has Genre and Joystick Port but no
+// Year, Publisher or Players column; other sheets may.
+var metadataColumns = map[string]string{
+	"genre":     "Genre",
+	"year":      "Year",
+	"publisher": "Publisher",
+	"port":      "Joystick Port",
+	"players":   "Players",
+}
+
+// Sheet is a loaded metadata CSV.
+type Sheet struct {
+	Games   []model.Game
+	headers map[string]int
+}
+
+// MissingColumns returns the sheet columns that metadata fields come from but the sheet lacks,
+// in the order of fields.
+func (s Sheet) MissingColumns(fields []string) []string {
+	var missing []string
+	for _, field := range fields {
+		column, ok := metadataColumns[field]
+		if !ok {
+			continue
+		}
+		if _, found := s.headers[strings.ToLower(column)]; !found {
+			missing = append(missing, column)
+		}
+	}
+	return missing
+}
+
 // LoadCSV reads a C64 Dreams metadata CSV and converts it into structured game data.
 func LoadCSV(ctx context.Context, path string) ([]model.Game, error) {
+	sheet, err := LoadSheet(ctx, path)
+	return sheet.Games, err
+}
+
+// LoadSheet reads a C64 Dreams metadata CSV like LoadCSV and keeps which columns it has.
+func LoadSheet(ctx context.Context, path string) (Sheet, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("open csv: %w", err)
+		return Sheet{}, fmt.Errorf("open csv: %w", err)
 	}
 	defer file.Close()
 
@@ -30,7 +69,7 @@ func LoadCSV(ctx context.Context, path string) ([]model.Game, error) {
 
 	for {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return Sheet{}, err
 		}
 
 		rec, err := reader.Read()
@@ -38,7 +77,7 @@ func LoadCSV(ctx context.Context, path string) ([]model.Game, error) {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("read csv: %w", err)
+			return Sheet{}, fmt.Errorf("read csv: %w", err)
 		}
 
 		// Skip empty rows.
@@ -65,6 +104,11 @@ func LoadCSV(ctx context.Context, path string) ([]model.Game, error) {
 		customNotes := strings.TrimSpace(value(rec, headers, "Custom Notes"))
 		gameNotes := strings.TrimSpace(value(rec, headers, "Game Notes"))
 		retroarchNotes := strings.TrimSpace(value(rec, headers, "Retroarch Notes"))
+		settings := model.Settings{
+			JoystickPort: parsePort(value(rec, headers, "Joystick Port")),
+			TrueDrive:    parseYes(value(rec, headers, "TrueDrive Enabled")),
+			Autowarp:     parseYes(value(rec, headers, "Autowarp")),
+		}
 
 		notes := joinNonEmpty(" | ", gameNotes, retroarchNotes, customNotes, source)
 
@@ -78,6 +122,7 @@ func LoadCSV(ctx context.Context, path string) ([]model.Game, error) {
 			ContentType:     ct,
 			SourcePath:      sourcePath,
 			Notes:           notes,
+			Settings:        settings,
 		}
 
 		game := model.Game{
@@ -85,13 +130,19 @@ func LoadCSV(ctx context.Context, path string) ([]model.Game, error) {
 			Title:          title,
 			NormalizedName: title,
 			Region:         model.RegionBoth,
-			Variants:       []model.Variant{variant},
+			Metadata: model.Metadata{
+				Genre:     strings.TrimSpace(value(rec, headers, "Genre")),
+				Year:      strings.TrimSpace(value(rec, headers, "Year")),
+				Publisher: strings.TrimSpace(value(rec, headers, "Publisher")),
+				Players:   strings.TrimSpace(value(rec, headers, "Players")),
+			},
+			Variants: []model.Variant{variant},
 		}
 
 		games = append(games, game)
 	}
 
-	return games, nil
+	return Sheet{Games: games, headers: headers}, nil
 }
 
 func hasHeader(rec []string, key string) bool {
@@ -204,6 +255,28 @@ func mapContentType(content string) model.ContentType {
 	}
 }
 
+// parsePort reads a joystick port column such as "2" or "Port 1"; anything else yields zero.
+func parsePort(raw string) int {
+	for _, r := range raw {
+		switch r {
+		case '1':
+			return 1
+		case '2':
+			return 2
+		}
+	}
+	return 0
+}
+
+func parseYes(raw string) bool {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "yes", "y", "true", "1", "x":
+		return true
+	default:
+		return false
+	}
+}
+
 func slugify(title string) string {
 	lower := strings.ToLower(title)
 	words := wordCharRegexp.FindAllString(lower, -1)
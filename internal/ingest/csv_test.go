@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/wazp/c64dreams-tool/pkg/model"
@@ -12,7 +13,7 @@ import (
 func TestLoadCSV(t *testing.T) {
 	content := "summary,,,,\n" +
 		",,Title,Type,Multi-disk,Joystick Port,TrueDrive Enabled,Autowarp,Autoload State,Genre,Manual,Zzap! Review 1,Zzap! Review 2,Zzap! Review 3,Game Notes,Retroarch Notes,PRG Name,Group,Version,Source,Custom Notes\n" +
-		"c,,Test Game,d64,,2,No,,,Shmup,Yes,70%,,,Note one,Retro note,PRG1,Remember,Test Game +1,CSDb,Custom note\n" +
+		"c,,Test Game,d64,,2,Yes,Yes,,Shmup,Yes,70%,,,Note one,Retro note,PRG1,Remember,Test Game +1,CSDb,Custom note\n" +
 		",,Second Game,prg,,2,No,,,Shmup,Yes,70%,,, , ,n/a,Group B,,GB64,\n"
 
 	dir := t.TempDir()
@@ -34,6 +35,9 @@ func TestLoadCSV(t *testing.T) {
 	if first.Title != "Test Game" || first.ID != "test-game" {
 		t.Fatalf("unexpected first game: %+v", first)
 	}
+	if first.Metadata.Genre != "Shmup" {
+		t.Fatalf("unexpected genre: %q", first.Metadata.Genre)
+	}
 	if len(first.Variants) != 1 {
 		t.Fatalf("expected 1 variant, got %d", len(first.Variants))
 	}
@@ -50,6 +54,9 @@ func TestLoadCSV(t *testing.T) {
 	if variant.Notes != "Note one | Retro note | Custom note | CSDb" {
 		t.Fatalf("unexpected notes: %q", variant.Notes)
 	}
+	if variant.Settings.JoystickPort != 2 || !variant.Settings.TrueDrive || !variant.Settings.Autowarp {
+		t.Fatalf("unexpected settings: %+v", variant.Settings)
+	}
 
 	second := games[1]
 	if second.Variants[0].ContentType != model.ContentPrg {
@@ -58,4 +65,34 @@ func TestLoadCSV(t *testing.T) {
 	if second.Variants[0].SourcePath != "Second Game.prg" { // falls back to title when PRG name is n/a
 		t.Fatalf("unexpected fallback source path: %s", second.Variants[0].SourcePath)
 	}
+	if second.Variants[0].Settings.TrueDrive {
+		t.Fatalf("expected TrueDrive disabled for second game")
+	}
+}
+
+func TestSheetMissingColumns(t *testing.T) {
+	content := ",,Title,Type,Multi-disk,Joystick Port,TrueDrive Enabled,Autowarp,Autoload State,Genre,Manual,Zzap! Review 1,Zzap! Review 2,Zzap! Review 3,Game Notes,Retroarch Notes,PRG Name,Group,Version,Source,Custom Notes\n" +
+		",,Test Game,d64,,2,Yes,Yes,,Shmup,Yes,70%,,,,,PRG1,Remember,,CSDb,\n"
+
+	path := filepath.Join(t.TempDir(), "games.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write csv: %v", err)
+	}
+
+	sheet, err := LoadSheet(context.Background(), path)
+	if err != nil {
+		t.Fatalf("LoadSheet returned error: %v", err)
+	}
+	if len(sheet.Games) != 1 {
+		t.Fatalf("expected 1 game, got %d", len(sheet.Games))
+	}
+
+	// the C64 Dreams sheet has no year, publisher or players column
+	missing := sheet.MissingColumns([]string{"genre", "year", "publisher", "port", "players"})
+	if strings.Join(missing, ",") != "Year,Publisher,Players" {
+		t.Fatalf("unexpected missing columns: %v", missing)
+	}
+	if missing := sheet.MissingColumns([]string{"genre", "port"}); len(missing) != 0 {
+		t.Fatalf("genre and port come from existing columns, got %v", missing)
+	}
 }
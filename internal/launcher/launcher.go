@@ -0,0 +1,87 @@
+package launcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/wazp/c64dreams-tool/internal/executor"
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// Launcher names accepted in TargetProfile.Launcher.
+const (
+	MiSTer = "mister"
+	VICE   = "vice"
+)
+
+// Known reports whether a launcher name has a generator.
+func Known(name string) bool {
+	switch name {
+	case "", MiSTer, VICE:
+		return true
+	default:
+		return false
+	}
+}
+
+// Generate builds the target's launcher files from the files an Apply run placed (or would place in dry-run).
+// The returned files carry their content in Body and can be passed to executor.Apply.
+func Generate(target model.TargetDevice, planned []layout.PlannedFile, results []executor.Result, outputRoot string) ([]layout.PlannedFile, error) {
+	name := model.ProfileFor(target).Launcher
+	if name == "" {
+		return nil, nil
+	}
+
+	outputAbs, err := filepath.Abs(outputRoot)
+	if err != nil {
+		return nil, fmt.Errorf("resolve output root: %w", err)
+	}
+	placed, err := placedFiles(results, outputAbs)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case MiSTer:
+		return misterLaunchers(planned, placed), nil
+	case VICE:
+		return viceLaunchers(planned, placed), nil
+	default:
+		return nil, fmt.Errorf("unknown launcher %q", name)
+	}
+}
+
+// placedFiles groups the output-relative destinations of copied or existing files by variant, sorted by path.
+func placedFiles(results []executor.Result, outputAbs string) (map[string][]string, error) {
+	placed := make(map[string][]string)
+	for _, r := range results {
+		if r.VariantID == "" {
+			continue
+		}
+		switch r.Action {
+		case "copy", "unchanged", "skip":
+		default:
+			continue
+		}
+		rel, err := filepath.Rel(outputAbs, r.Dest)
+		if err != nil {
+			return nil, fmt.Errorf("relative destination: %w", err)
+		}
+		placed[r.VariantID] = append(placed[r.VariantID], filepath.ToSlash(rel))
+	}
+	for id := range placed {
+		sort.Strings(placed[id])
+	}
+	return placed, nil
+}
+
+// variantCounts counts planned variants per game so launchers can tell multi-variant games apart.
+func variantCounts(planned []layout.PlannedFile) map[string]int {
+	counts := make(map[string]int)
+	for _, p := range planned {
+		counts[p.GameID]++
+	}
+	return counts
+}
@@ -0,0 +1,151 @@
+package launcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/executor"
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestMiSTerLaunchers(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Jumpman/jumpman.d64"), "disk")
+	mustWrite(t, filepath.Join(src, "Jumpman/extra.d64"), "disk2")
+	mustWrite(t, filepath.Join(src, "Paradroid/paradroid.crt"), "cart")
+
+	planned := []layout.PlannedFile{
+		{GameID: "jumpman", VariantID: "jumpman-0", Target: model.TargetMiSTer, Source: "Jumpman/jumpman.d64", Content: model.ContentDisk, Path: "games/C64/j/jumpman/jumpman.d64"},
+		{GameID: "paradroid", VariantID: "paradroid-0", Target: model.TargetMiSTer, Source: "Paradroid/paradroid.crt", Content: model.ContentCart, Path: "games/C64/p/paradroid/paradroid.crt"},
+	}
+
+	opts := executor.Options{InputRoot: src, OutputRoot: dst}
+	results, err := executor.Apply(context.Background(), planned, opts)
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	generated, err := Generate(model.TargetMiSTer, planned, results, dst)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(generated) != 2 {
+		t.Fatalf("expected 2 launchers, got %d", len(generated))
+	}
+
+	if generated[0].Path != "_C64/j/jumpman.mgl" {
+		t.Fatalf("unexpected launcher path: %s", generated[0].Path)
+	}
+	if !strings.Contains(generated[0].Body, `type="s" index="0" path="j/jumpman/jumpman.d64"`) {
+		t.Fatalf("unexpected disk launcher:\n%s", generated[0].Body)
+	}
+	if !strings.Contains(generated[1].Body, `type="f" index="1" path="p/paradroid/paradroid.crt"`) {
+		t.Fatalf("unexpected cart launcher:\n%s", generated[1].Body)
+	}
+
+	if _, err := executor.Apply(context.Background(), generated, opts); err != nil {
+		t.Fatalf("Apply of launchers returned error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "_C64", "p", "paradroid.mgl"))
+	if err != nil {
+		t.Fatalf("launcher not written: %v", err)
+	}
+	if !strings.Contains(string(data), "<rbf>_Computer/C64</rbf>") {
+		t.Fatalf("unexpected launcher content:\n%s", data)
+	}
+}
+
+func TestMiSTerLauncherForFlatGame(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Paradroid/paradroid.crt"), "cart")
+
+	planned := []layout.PlannedFile{
+		{GameID: "paradroid", VariantID: "paradroid-0", Target: model.TargetMiSTer, Source: "Paradroid/paradroid.crt", Content: model.ContentCart,
+			Path: "games/C64/p/paradroid.crt", GameDir: "games/C64/p/paradroid"},
+	}
+
+	results, err := executor.Apply(context.Background(), planned, executor.Options{InputRoot: src, OutputRoot: dst, DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	generated, err := Generate(model.TargetMiSTer, planned, results, dst)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if len(generated) != 1 || generated[0].Path != "_C64/p/paradroid.mgl" {
+		t.Fatalf("unexpected launchers: %+v", generated)
+	}
+}
+
+func TestVICELaunchers(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "input")
+	dst := filepath.Join(root, "output")
+
+	mustWrite(t, filepath.Join(src, "Ultima/ultima 4 disk 1.d64"), "d1")
+	mustWrite(t, filepath.Join(src, "Ultima/ultima 4 disk 2.d64"), "d2")
+	mustWrite(t, filepath.Join(src, "Jumpman/jumpman.prg"), "prg")
+
+	planned := []layout.PlannedFile{
+		{GameID: "ultima", VariantID: "ultima-0", Target: model.TargetVICE, Source: "Ultima/ultima 4 disk 1.d64", Content: model.ContentDisk, Path: "u/ultima 4/ultima 4 disk 1.d64",
+			Settings: model.Settings{TrueDrive: true}},
+		{GameID: "jumpman", VariantID: "jumpman-0", Target: model.TargetVICE, Source: "Jumpman/jumpman.prg", Content: model.ContentPrg, Path: "j/jumpman/jumpman.prg",
+			Settings: model.Settings{JoystickPort: 2, Autowarp: true}},
+	}
+
+	results, err := executor.Apply(context.Background(), planned, executor.Options{InputRoot: src, OutputRoot: dst, DryRun: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+
+	generated, err := Generate(model.TargetVICE, planned, results, dst)
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	bodies := map[string]string{}
+	for _, g := range generated {
+		bodies[g.Path] = g.Body
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("expected fliplist and two option files, got %v", bodies)
+	}
+
+	flip := bodies["u/ultima 4/ultima 4 disk 1.vfl"]
+	if flip != "# Vice fliplist file\n\nUNIT 8\nultima 4 disk 1.d64\nultima 4 disk 2.d64\n" {
+		t.Fatalf("unexpected fliplist:\n%s", flip)
+	}
+	if got := bodies["u/ultima 4/ultima 4 disk 1.args"]; got != `-drive8truedrive +autostart-warp -flipname "ultima 4 disk 1.vfl" -autostart "ultima 4 disk 1.d64"`+"\n" {
+		t.Fatalf("unexpected multi-disk options: %q", got)
+	}
+	if got := bodies["j/jumpman/jumpman.args"]; got != `+drive8truedrive -autostart-warp -joydev2 4 -autostart "jumpman.prg"`+"\n" {
+		t.Fatalf("unexpected single-file options: %q", got)
+	}
+}
+
+func TestGenerateWithoutLauncher(t *testing.T) {
+	generated, err := Generate(model.TargetSD2IEC, nil, nil, t.TempDir())
+	if err != nil || generated != nil {
+		t.Fatalf("expected no launchers for sd2iec, got %v (%v)", generated, err)
+	}
+}
+
+func mustWrite(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir for file: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+}
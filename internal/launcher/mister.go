@@ -0,0 +1,99 @@
+package launcher
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+const (
+	misterGamesDir = "games/C64" // The C64 core resolves relative MGL paths against this folder
+	misterMenuDir  = "_C64"      // Underscore folders at the SD root show up in the MiSTer menu
+	misterCore     = "_Computer/C64"
+	misterFATRoot  = "/media/fat"
+)
+
+// misterLaunchers writes one MGL file per planned variant that has a placed file,
+// mirroring the game's bucket folders under the menu directory.
+func misterLaunchers(planned []layout.PlannedFile, placed map[string][]string) []layout.PlannedFile {
+	counts := variantCounts(planned)
+
+	var out []layout.PlannedFile
+	for _, p := range planned {
+		files := placed[p.VariantID]
+		if len(files) == 0 || p.Body != "" {
+			continue
+		}
+		primary := files[0]
+		if primaryPath, ok := plannedPrimary(p, files); ok {
+			primary = primaryPath
+		}
+
+		inGames := strings.TrimPrefix(primary, misterGamesDir+"/")
+		gameDir := path.Dir(inGames)
+		if p.GameDir != "" && path.Dir(primary) == path.Dir(p.Path) {
+			// flat game: the file itself stands in for the game folder
+			gameDir = strings.TrimSuffix(inGames, path.Ext(inGames))
+		}
+		name := path.Base(gameDir)
+		if counts[p.GameID] > 1 {
+			name += " - " + strings.TrimSuffix(path.Base(primary), path.Ext(primary))
+		}
+		mglPath := path.Join(misterMenuDir, name+".mgl")
+		if bucket := path.Dir(gameDir); bucket != "." && strings.HasPrefix(primary, misterGamesDir+"/") {
+			mglPath = path.Join(misterMenuDir, bucket, name+".mgl")
+		}
+
+		out = append(out, layout.PlannedFile{
+			GameID:    p.GameID,
+			VariantID: p.VariantID + "-mgl",
+			Target:    p.Target,
+			Title:     p.Title,
+			Path:      mglPath,
+			Body:      mgl(primary),
+		})
+	}
+	return out
+}
+
+// plannedPrimary picks the placed file that matches the planned name, ignoring its extension.
+// Flat games that were moved into their game folder are matched there too.
+func plannedPrimary(p layout.PlannedFile, files []string) (string, bool) {
+	stems := []string{strings.TrimSuffix(p.Path, path.Ext(p.Path))}
+	if p.GameDir != "" {
+		stems = append(stems, path.Join(p.GameDir, path.Base(stems[0])))
+	}
+	for _, stem := range stems {
+		for _, f := range files {
+			if strings.TrimSuffix(f, path.Ext(f)) == stem {
+				return f, true
+			}
+		}
+	}
+	return "", false
+}
+
+// mgl renders a MiSTer Game Launcher file that loads rel with the C64 core.
+// Disk images are mounted on drive 8; PRG, CRT and TAP go through the core's file loader.
+func mgl(rel string) string {
+	loadType, index := "f", 1
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(rel), ".")) {
+	case "d64", "g64", "d71", "d81", "t64":
+		loadType, index = "s", 0
+	}
+
+	target := strings.TrimPrefix(rel, misterGamesDir+"/")
+	if target == rel {
+		target = path.Join(misterFATRoot, rel)
+	}
+
+	var escaped bytes.Buffer
+	_ = xml.EscapeText(&escaped, []byte(target))
+
+	return fmt.Sprintf("<mistergamedescription>\n\t<rbf>%s</rbf>\n\t<file delay=\"1\" type=\"%s\" index=\"%d\" path=\"%s\"/>\n</mistergamedescription>\n",
+		misterCore, loadType, index, escaped.String())
+}
@@ -0,0 +1,100 @@
+package launcher
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+// viceHostJoystick is VICE's joystick device number for the first host joystick.
+const viceHostJoystick = 4
+
+// viceLaunchers writes, next to each game's files, an option file with the VICE command-line
+// arguments that start it the way C64 Dreams configures it, plus a fliplist for multi-disk games.
+// Paths inside both files are relative to the game folder, so VICE should be started from there.
+func viceLaunchers(planned []layout.PlannedFile, placed map[string][]string) []layout.PlannedFile {
+	var out []layout.PlannedFile
+	for _, p := range planned {
+		files := placed[p.VariantID]
+		if len(files) == 0 || p.Body != "" {
+			continue
+		}
+		primary := files[0]
+		if primaryPath, ok := plannedPrimary(p, files); ok {
+			primary = primaryPath
+		}
+		dir := path.Dir(primary)
+		stem := strings.TrimSuffix(path.Base(primary), path.Ext(primary))
+
+		var args []string
+		if p.Settings.TrueDrive {
+			args = append(args, "-drive8truedrive")
+		} else {
+			args = append(args, "+drive8truedrive")
+		}
+		if p.Settings.Autowarp {
+			args = append(args, "-autostart-warp")
+		} else {
+			args = append(args, "+autostart-warp")
+		}
+		if p.Settings.JoystickPort > 0 {
+			args = append(args, fmt.Sprintf("-joydev%d %d", p.Settings.JoystickPort, viceHostJoystick))
+		}
+
+		var disks []string
+		for _, f := range files {
+			if path.Dir(f) == dir && isDiskImage(f) {
+				disks = append(disks, path.Base(f))
+			}
+		}
+		if len(disks) > 1 {
+			flipName := stem + ".vfl"
+			out = append(out, layout.PlannedFile{
+				GameID:    p.GameID,
+				VariantID: p.VariantID + "-vfl",
+				Target:    p.Target,
+				Title:     p.Title,
+				Path:      path.Join(dir, flipName),
+				Body:      fliplist(disks),
+			})
+			args = append(args, "-flipname "+quoteArg(flipName))
+		}
+
+		args = append(args, "-autostart "+quoteArg(path.Base(primary)))
+		out = append(out, layout.PlannedFile{
+			GameID:    p.GameID,
+			VariantID: p.VariantID + "-args",
+			Target:    p.Target,
+			Title:     p.Title,
+			Path:      path.Join(dir, stem+".args"),
+			Body:      strings.Join(args, " ") + "\n",
+		})
+	}
+	return out
+}
+
+// fliplist renders a VICE fliplist that cycles the given disk images on drive 8.
+func fliplist(disks []string) string {
+	var b strings.Builder
+	b.WriteString("# Vice fliplist file\n\nUNIT 8\n")
+	for _, d := range disks {
+		b.WriteString(d)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func isDiskImage(name string) bool {
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")) {
+	case "d64", "d71", "d81", "g64":
+		return true
+	default:
+		return false
+	}
+}
+
+func quoteArg(arg string) string {
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+}
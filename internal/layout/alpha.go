@@ -0,0 +1,140 @@
+package layout
+
+import (
+	"sort"
+	"strings"
+)
+
+// defaultScreenEntries is how many entries fit on one C64 screen of a file browser.
+const defaultScreenEntries = 22
+
+// nestAlpha replaces the letter-grouping slot of each placement with nested alpha folders
+// (e.g. "a/ab/abc" or "a-f") sized from the real name counts within each parent directory, so
+// each folder fits within limit entries, one screen by default.
+func nestAlpha(places []placement, depth, limit int) {
+	if limit <= 0 {
+		limit = defaultScreenEntries
+	}
+
+	groups := make(map[string][]int)
+	for i, pl := range places {
+		if pl.alpha < 0 {
+			continue
+		}
+		key := strings.Join(pl.parts[:pl.alpha], "/")
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, members := range groups {
+		seen := make(map[string]struct{})
+		var names []string
+		for _, i := range members {
+			name := strings.ToLower(places[i].parts[places[i].entry])
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		tree := alphaTree(names, depth, limit)
+		for _, i := range members {
+			pl := &places[i]
+			folders := tree[strings.ToLower(pl.parts[pl.entry])]
+			parts := make([]string, 0, len(pl.parts)+len(folders)-1)
+			parts = append(parts, pl.parts[:pl.alpha]...)
+			parts = append(parts, folders...)
+			parts = append(parts, pl.parts[pl.alpha+1:]...)
+			pl.parts = parts
+			pl.entry += len(folders) - 1
+			pl.alpha = -1
+		}
+	}
+}
+
+type alphaGroup struct {
+	key   string
+	names []string
+}
+
+// alphaTree assigns each sorted name its alpha folders. At each level, names are grouped by a
+// prefix one character longer than the level above; neighbouring groups are packed into one
+// folder ("a-f") while they fit within limit, and a single prefix that is still too big is broken
+// down by the next character until depth levels are used.
+func alphaTree(names []string, depth, limit int) map[string][]string {
+	out := make(map[string][]string, len(names))
+
+	var walk func(names []string, level int, prefix []string)
+	walk = func(names []string, level int, prefix []string) {
+		var groups []alphaGroup
+		for _, n := range names {
+			key := alphaKey(n, level)
+			if len(groups) > 0 && groups[len(groups)-1].key == key {
+				groups[len(groups)-1].names = append(groups[len(groups)-1].names, n)
+				continue
+			}
+			groups = append(groups, alphaGroup{key: key, names: []string{n}})
+		}
+
+		var bin []alphaGroup
+		count := 0
+		flush := func() {
+			if len(bin) == 0 {
+				return
+			}
+			label := bin[0].key
+			if last := bin[len(bin)-1].key; last != label {
+				label += "-" + last
+			}
+			next := append(append([]string(nil), prefix...), label)
+
+			var members []string
+			for _, g := range bin {
+				members = append(members, g.names...)
+			}
+			if len(bin) == 1 && len(members) > limit && level < depth {
+				walk(members, level+1, next)
+			} else {
+				for _, n := range members {
+					out[n] = next
+				}
+			}
+			bin, count = nil, 0
+		}
+
+		for _, g := range groups {
+			if count > 0 && count+len(g.names) > limit {
+				flush()
+			}
+			bin = append(bin, g)
+			count += len(g.names)
+			if count > limit {
+				flush()
+			}
+		}
+		flush()
+	}
+
+	walk(names, 1, nil)
+	return out
+}
+
+// alphaKey returns the folder key for a name at a given prefix length.
+func alphaKey(name string, level int) string {
+	runes := []rune(strings.ToLower(strings.TrimSpace(name)))
+	if len(runes) == 0 {
+		return "misc"
+	}
+	first := runes[0]
+	if !(first >= 'a' && first <= 'z') && !(first >= '0' && first <= '9') {
+		return "misc"
+	}
+	if level > len(runes) {
+		level = len(runes)
+	}
+	key := strings.TrimRight(string(runes[:level]), " -")
+	if key == "" {
+		return "misc"
+	}
+	return key
+}
@@ -0,0 +1,72 @@
+package layout
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// resolveFlatClashes renames flat files that land on the same name in a shared directory,
+// comparing case-insensitively as FAT does. The first keeps its name; later ones get "~1", "~2", ...
+// inside the target's name limit. A flat file also claims the folder named after its stem, which
+// it moves into when its source turns out to have companions, so it is renamed as well when that
+// folder belongs to a game folder or another flat file. It runs after directories are split, on
+// the final folders.
+func resolveFlatClashes(places []placement, planned []PlannedFile) {
+	taken := make(map[string]struct{})
+	for _, pl := range places {
+		if !pl.flat {
+			taken[strings.ToLower(path.Join(pl.parts[:pl.entry+1]...))] = struct{}{}
+		}
+	}
+	claim := func(dir, name string) bool {
+		file := strings.ToLower(path.Join(dir, name))
+		folder := strings.ToLower(path.Join(dir, strings.TrimSuffix(name, path.Ext(name))))
+		_, fileTaken := taken[file]
+		_, folderTaken := taken[folder]
+		if fileTaken || folderTaken {
+			return false
+		}
+		taken[file], taken[folder] = struct{}{}, struct{}{}
+		return true
+	}
+
+	for i := range places {
+		pl := &places[i]
+		if !pl.flat {
+			continue
+		}
+		dir := path.Join(pl.parts[:pl.entry]...)
+		name := pl.parts[pl.entry]
+		if claim(dir, name) {
+			continue
+		}
+
+		ext := path.Ext(name)
+		stem := strings.TrimSuffix(name, ext)
+		limit := model.ProfileFor(planned[i].Target).MaxNameLen
+		for n := 1; ; n++ {
+			candidate := SuffixStem(stem, n, limit) + ext
+			if claim(dir, candidate) {
+				pl.parts[pl.entry] = candidate
+				break
+			}
+		}
+	}
+}
+
+// SuffixStem appends "~n" to stem, trimming the stem so the result stays within limit runes.
+func SuffixStem(stem string, n, limit int) string {
+	suffix := fmt.Sprintf("~%d", n)
+	runes := []rune(stem)
+	if limit > 0 && len(runes)+len([]rune(suffix)) > limit {
+		keep := limit - len([]rune(suffix))
+		if keep < 0 {
+			keep = 0
+		}
+		runes = runes[:keep]
+	}
+	return strings.TrimRight(string(runes), " -") + suffix
+}
@@ -0,0 +1,151 @@
+package layout
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// Grouping names a folder level Plan inserts between the base directory and the game folder.
+type Grouping string
+
+const (
+	GroupLetter       Grouping = "letter"
+	GroupMedia        Grouping = "media"
+	GroupGenre        Grouping = "genre"
+	GroupYear         Grouping = "year"
+	GroupPublisher    Grouping = "publisher"
+	GroupJoystickPort Grouping = "joystick-port"
+	GroupPlayers      Grouping = "players"
+)
+
+// unknownGroup is the folder for games whose metadata does not provide a grouping value.
+const unknownGroup = "unknown"
+
+var yearRegexp = regexp.MustCompile(`\b(19|20)\d\d\b`)
+
+// Groupings lists every supported grouping strategy.
+func Groupings() []Grouping {
+	return []Grouping{GroupLetter, GroupMedia, GroupGenre, GroupYear, GroupPublisher, GroupJoystickPort, GroupPlayers}
+}
+
+// ParseGroupings validates grouping names in order. "none" on its own yields no grouping.
+func ParseGroupings(names []string) ([]Grouping, error) {
+	if len(names) == 1 && strings.EqualFold(strings.TrimSpace(names[0]), "none") {
+		return nil, nil
+	}
+
+	seen := make(map[Grouping]struct{})
+	var out []Grouping
+	for _, name := range names {
+		g := Grouping(strings.ToLower(strings.TrimSpace(name)))
+		if !validGrouping(g) {
+			return nil, fmt.Errorf("invalid grouping %q", name)
+		}
+		if _, dup := seen[g]; dup {
+			return nil, fmt.Errorf("grouping %q listed more than once", name)
+		}
+		seen[g] = struct{}{}
+		out = append(out, g)
+	}
+	return out, nil
+}
+
+func validGrouping(g Grouping) bool {
+	for _, known := range Groupings() {
+		if g == known {
+			return true
+		}
+	}
+	return false
+}
+
+// groupings resolves the strategies to apply, falling back to the media/alpha switches.
+func (o Options) groupings() []Grouping {
+	if len(o.GroupBy) > 0 {
+		return o.GroupBy
+	}
+	var out []Grouping
+	if o.GroupByMedia {
+		out = append(out, GroupMedia)
+	}
+	if o.GroupByAlpha {
+		out = append(out, GroupLetter)
+	}
+	return out
+}
+
+// metadataGroupings maps the groupings that read sheet metadata to their placeholder names.
+var metadataGroupings = map[Grouping]string{
+	GroupGenre:        "genre",
+	GroupYear:         "year",
+	GroupPublisher:    "publisher",
+	GroupJoystickPort: "port",
+	GroupPlayers:      "players",
+}
+
+// MetadataFields lists the sheet metadata the layout reads through its groupings or path
+// template, by placeholder name ("genre", "year", "publisher", "port", "players"), in that order.
+// An invalid template contributes nothing; Plan reports it.
+func (o Options) MetadataFields() []string {
+	used := make(map[string]bool)
+	for _, g := range o.groupings() {
+		if field, ok := metadataGroupings[g]; ok {
+			used[field] = true
+		}
+	}
+	if o.PathTemplate != "" {
+		if tmpl, err := ParseTemplate(o.PathTemplate); err == nil {
+			for _, field := range metadataGroupings {
+				if tmpl.uses(field) {
+					used[field] = true
+				}
+			}
+		}
+	}
+
+	var fields []string
+	for _, field := range []string{"genre", "year", "publisher", "port", "players"} {
+		if used[field] {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// groupFolder returns the folder name a grouping assigns to a variant of a game.
+func groupFolder(g Grouping, game model.NormalizedGame, v model.NormalizedVariant, alphaSize int, mediaDirs map[string]string, rules nameRules) (string, error) {
+	switch g {
+	case GroupLetter:
+		return alphaBucket(game.Name.Normalized, alphaSize), nil
+	case GroupMedia:
+		return mediaFolder(variantExt(v), mediaDirs), nil
+	case GroupGenre:
+		return metadataFolder(rules, game.Metadata.Genre), nil
+	case GroupYear:
+		if year := yearRegexp.FindString(game.Metadata.Year); year != "" {
+			return year, nil
+		}
+		return unknownGroup, nil
+	case GroupPublisher:
+		return metadataFolder(rules, game.Metadata.Publisher), nil
+	case GroupJoystickPort:
+		if v.Settings.JoystickPort > 0 {
+			return fmt.Sprintf("port%d", v.Settings.JoystickPort), nil
+		}
+		return unknownGroup, nil
+	case GroupPlayers:
+		return metadataFolder(rules, game.Metadata.Players), nil
+	default:
+		return "", fmt.Errorf("invalid grouping %q", g)
+	}
+}
+
+func metadataFolder(rules nameRules, value string) string {
+	if name := rules.name(value); name != "" {
+		return name
+	}
+	return unknownGroup
+}
@@ -1,20 +1,86 @@
 package layout
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Canonical media groups.
+const (
+	MediaDisks   = "disks"
+	MediaTape    = "tape"
+	MediaCart    = "cart"
+	MediaPrg     = "prg"
+	MediaZip     = "zip"
+	MediaUnknown = "unknown"
+)
+
+// mediaGroups maps each C64 file extension to its media group.
+var mediaGroups = map[string]string{
+	"d64": MediaDisks,
+	"d71": MediaDisks,
+	"d81": MediaDisks,
+	"g64": MediaDisks,
+	"tap": MediaTape,
+	"t64": MediaTape,
+	"crt": MediaCart,
+	"ef":  MediaCart,
+	"prg": MediaPrg,
+	"zip": MediaZip,
+}
+
+// MediaGroups lists every media group, including the one for unrecognized extensions.
+func MediaGroups() []string {
+	return []string{MediaDisks, MediaTape, MediaCart, MediaPrg, MediaZip, MediaUnknown}
+}
 
 // MediaGroupFor maps a file extension to a canonical media group.
 func MediaGroupFor(ext string) (string, error) {
 	clean := strings.ToLower(strings.TrimSpace(ext))
 	clean = strings.TrimPrefix(clean, ".")
 
-	switch clean {
-	case "d64", "d71", "d81", "g64":
-		return "disks", nil
-	case "tap", "t64":
-		return "tape", nil
-	case "crt":
-		return "cart", nil
-	default:
-		return "unknown", nil
+	if group, ok := mediaGroups[clean]; ok {
+		return group, nil
+	}
+	return MediaUnknown, nil
+}
+
+// mediaFolder returns the folder for an extension's media group, renamed by dirs when it has an entry.
+// Groups renamed to the same folder share it.
+func mediaFolder(ext string, dirs map[string]string) string {
+	group, _ := MediaGroupFor(ext)
+	if name, ok := dirs[group]; ok {
+		return strings.TrimSpace(name)
+	}
+	return group
+}
+
+// ValidateMediaDirs checks that media folder overrides name known groups and single folders.
+func ValidateMediaDirs(dirs map[string]string) error {
+	groups := make([]string, 0, len(dirs))
+	for group := range dirs {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		if !validMediaGroup(group) {
+			return fmt.Errorf("unknown media group %q (expected one of: %s)", group, strings.Join(MediaGroups(), ", "))
+		}
+		name := strings.TrimSpace(dirs[group])
+		if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+			return fmt.Errorf("media group %q: folder %q must be a single folder name", group, dirs[group])
+		}
+	}
+	return nil
+}
+
+func validMediaGroup(group string) bool {
+	for _, known := range MediaGroups() {
+		if group == known {
+			return true
+		}
 	}
+	return false
 }
@@ -2,11 +2,33 @@ package layout
 
 // Options defines how output paths should be organized.
 type Options struct {
-	BaseDir         string
-	GroupByMedia    bool
-	GroupByAlpha    bool
-	AlphaBucketSize int
+	BaseDir          string
+	GroupBy          []Grouping // Folder levels in order; when empty, GroupByMedia then GroupByAlpha apply
+	GroupByMedia     bool
+	GroupByAlpha     bool
+	AlphaBucketSize  int
+	AlphaDepth       int // Above 1, letter grouping nests up to this many prefix levels sized to fit one screen
+	ScreenEntries    int // Entries per nested letter folder with AlphaDepth; zero means defaultScreenEntries
+	Unsupported      UnsupportedPolicy
+	MaxEntriesPerDir int               // Split directories holding more game entries than this into name ranges; zero disables
+	PathTemplate     string            // Replaces grouping and game folders, e.g. "{media}/{alpha:2}/{name}/{label}.{ext}"
+	FilenameFlags    bool              // Append TheC64 launch flags (_J1, _J2, _NTSC, _TDE) to file names
+	Flat             bool              // Place single-file games directly in their bucket instead of a game folder
+	MediaDirs        map[string]string // Folder name per media group (e.g. "disks": "DISKS"); groups sharing a name merge
 }
 
+// UnsupportedPolicy decides what Plan does with variants the target device cannot use.
+type UnsupportedPolicy string
+
+const (
+	UnsupportedKeep UnsupportedPolicy = ""     // Plan everything without checking capabilities
+	UnsupportedWarn UnsupportedPolicy = "warn" // Plan the variant but set PlannedFile.Warning
+	UnsupportedSkip UnsupportedPolicy = "skip" // Leave the variant out of the plan
+
+	// UnsupportedConvert extracts zip variants the target cannot open (see PlannedFile.Extract)
+	// and leaves out other unsupported variants, which have no conversion.
+	UnsupportedConvert UnsupportedPolicy = "convert"
+)
+
 // defaultAlphaBucketSize is used when GroupByAlpha is true but size is not set.
 const defaultAlphaBucketSize = 1
@@ -3,20 +3,40 @@ package layout
 import (
 	"fmt"
 	"path"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/wazp/c64dreams-tool/pkg/model"
 )
 
 // PlannedFile represents a variant placed into a relative path.
 type PlannedFile struct {
+	GameID    string             `json:"gameId"`
+	VariantID string             `json:"variantId"`
+	Target    model.TargetDevice `json:"target"`
+	Source    string             `json:"source,omitempty"`
+	Title     string             `json:"title,omitempty"`
+	Content   model.ContentType  `json:"content,omitempty"`
+	Path      string             `json:"path"`
+	Settings  model.Settings     `json:"settings,omitzero"`
+	Body      string             `json:"body,omitempty"`     // Generated content written instead of copying Source (launchers, playlists)
+	GameDir   string             `json:"gameDir,omitempty"`  // Set for flat single-file games: the game folder to use if the source has companions
+	Resolved  bool               `json:"resolved,omitempty"` // Source is an exact input-relative file copied to Path as is, without sibling lookup
+	Size      int64              `json:"size,omitempty"`     // Source size in bytes, recorded when Resolved
+	Warning   string             `json:"warning,omitempty"`  // Set when the target cannot use this content but the plan keeps it
+	Extract   bool               `json:"extract,omitempty"`  // Source is a zip the target cannot open; its C64 files go to Path's folder under their own names
+}
+
+// Exclusion records a variant the target device cannot use.
+type Exclusion struct {
 	GameID    string
 	VariantID string
-	Target    model.TargetDevice
-	Source    string
 	Title     string
+	Target    model.TargetDevice
 	Content   model.ContentType
-	Path      string
+	Reason    string
 }
 
 // Plan maps normalized games into relative output paths without touching the filesystem.
@@ -26,49 +46,99 @@ func Plan(games []model.NormalizedGame, opts Options) ([]PlannedFile, error) {
 		alphaSize = defaultAlphaBucketSize
 	}
 
+	var tmpl *Template
+	if opts.PathTemplate != "" {
+		parsed, err := ParseTemplate(opts.PathTemplate)
+		if err != nil {
+			return nil, err
+		}
+		if opts.AlphaDepth > 1 {
+			// the template replaces grouping, so there is no letter level to nest
+			return nil, fmt.Errorf("alpha depth cannot be combined with a path template; use {alpha:N} instead")
+		}
+		tmpl = parsed
+	}
+
 	var planned []PlannedFile
+	var places []placement
+
+	groupings := opts.groupings()
 
 	for gi, g := range games {
-		gameDir := sanitizeName(g.Name.Normalized)
-		bucket := ""
-		if opts.GroupByAlpha {
-			bucket = alphaBucket(g.Name.Normalized, alphaSize)
-		}
+		rules := nameRulesFor(g.Target)
+		gameDir := rules.name(g.Name.Normalized)
+		flat := opts.Flat && tmpl == nil && keptVariants(g, opts) == 1
 
 		for vi, v := range g.Variants {
-			ext := extensionForContent(v.ContentType)
-			components := make([]string, 0, 5)
-			if opts.BaseDir != "" {
-				components = append(components, sanitizePathPart(opts.BaseDir))
-			}
-
-			if opts.GroupByMedia {
-				media, err := MediaGroupFor(ext)
-				if err != nil {
-					return nil, err
+			warning := ""
+			extract := false
+			if reason, ok := unsupportedReason(g.Target, v); !ok {
+				switch {
+				case opts.Unsupported == UnsupportedConvert && v.ContentType == model.ContentZip:
+					extract = true
+				case opts.Unsupported == UnsupportedSkip, opts.Unsupported == UnsupportedConvert:
+					continue
+				case opts.Unsupported == UnsupportedWarn:
+					warning = reason
 				}
-				components = append(components, media)
 			}
 
-			if bucket != "" {
-				components = append(components, bucket)
+			ext := plannedExtension(g.Target, v)
+			components := make([]string, 0, len(groupings)+3)
+			if opts.BaseDir != "" {
+				components = append(components, sanitizePathPart(opts.BaseDir))
 			}
 
-			components = append(components, gameDir)
-
 			baseName := v.Label.Normalized
 			if v.SourcePath != "" {
 				baseName = path.Base(v.SourcePath)
 			}
-			fileName := sanitizeFile(baseName, ext)
-
-			components = append(components, fileName)
+			fileName := rules.file(baseName, ext)
 
 			src := v.SourcePath
 			if src == "" {
 				src = path.Join(gameDir, fileName)
 			}
 
+			flags := ""
+			if opts.FilenameFlags {
+				flags = theC64Flags(v.Settings, v.Region)
+			}
+
+			if tmpl != nil {
+				parts, err := tmpl.expand(templateValues(g, v, vi, ext, baseName, flags, alphaSize, opts.MediaDirs), g.Name.Normalized, rules)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", g.Title, err)
+				}
+				if !tmpl.uses("flags") {
+					parts[len(parts)-1] = withFlags(parts[len(parts)-1], flags)
+				}
+				entry := len(components) + tmpl.entrySegment()
+				components = append(components, parts...)
+				places = append(places, placement{parts: components, entry: entry, alpha: -1})
+			} else {
+				alphaAt := -1
+				for _, grouping := range groupings {
+					if grouping == GroupLetter && opts.AlphaDepth > 1 {
+						alphaAt = len(components)
+						components = append(components, "")
+						continue
+					}
+					folder, err := groupFolder(grouping, g, v, alphaSize, opts.MediaDirs, rules)
+					if err != nil {
+						return nil, err
+					}
+					components = append(components, folder)
+				}
+				if flat {
+					components = append(components, withFlags(rules.file(gameDir, strings.TrimPrefix(path.Ext(fileName), ".")), flags))
+					places = append(places, placement{parts: components, entry: len(components) - 1, alpha: alphaAt, flat: true})
+				} else {
+					components = append(components, gameDir, withFlags(fileName, flags))
+					places = append(places, placement{parts: components, entry: len(components) - 2, alpha: alphaAt})
+				}
+			}
+
 			planned = append(planned, PlannedFile{
 				GameID:    g.ID,
 				VariantID: fmt.Sprintf("%s-%d", g.ID, vi),
@@ -77,6 +147,9 @@ func Plan(games []model.NormalizedGame, opts Options) ([]PlannedFile, error) {
 				Title:     g.Title,
 				Content:   v.ContentType,
 				Path:      path.Join(components...),
+				Settings:  v.Settings,
+				Warning:   warning,
+				Extract:   extract,
 			})
 		}
 
@@ -84,75 +157,217 @@ func Plan(games []model.NormalizedGame, opts Options) ([]PlannedFile, error) {
 		_ = gi
 	}
 
+	if opts.AlphaDepth > 1 {
+		nestAlpha(places, opts.AlphaDepth, opts.ScreenEntries)
+	}
+	if opts.MaxEntriesPerDir > 0 {
+		splitOverfull(places, planned, opts.MaxEntriesPerDir)
+	}
+	if opts.Flat {
+		resolveFlatClashes(places, planned)
+	}
+	for i := range planned {
+		planned[i].Path = path.Join(places[i].parts...)
+		if pl := places[i]; pl.flat {
+			name := pl.parts[pl.entry]
+			planned[i].GameDir = path.Join(path.Join(pl.parts[:pl.entry]...), strings.TrimSuffix(name, path.Ext(name)))
+		}
+	}
+
 	return planned, nil
 }
 
-func sanitizeName(name string) string {
-	runes := []rune(strings.TrimSpace(name))
-	var b strings.Builder
-	last := rune(0)
-	for _, r := range runes {
-		if r >= 'A' && r <= 'Z' {
-			r = r - 'A' + 'a'
-		}
-		switch {
-		case r == '_':
-			r = ' '
-		case r == '\'':
-			continue
-		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == ' ':
-			// keep
-		default:
-			r = '-'
+// templateValues collects the raw placeholder values for one variant.
+func templateValues(g model.NormalizedGame, v model.NormalizedVariant, index int, ext, baseName, flags string, alphaSize int, mediaDirs map[string]string) map[string]string {
+	if ext == "" {
+		ext = strings.ToLower(strings.TrimPrefix(path.Ext(baseName), "."))
+	}
+	media := mediaFolder(ext, mediaDirs)
+	port := unknownGroup
+	if v.Settings.JoystickPort > 0 {
+		port = fmt.Sprintf("port%d", v.Settings.JoystickPort)
+	}
+	year := unknownGroup
+	if y := yearRegexp.FindString(g.Metadata.Year); y != "" {
+		year = y
+	}
+	region := v.Region
+	if region == "" {
+		region = g.Region
+	}
+
+	return map[string]string{
+		"id":        g.ID,
+		"title":     g.Title,
+		"name":      g.Name.Normalized,
+		"label":     v.Label.Normalized,
+		"file":      strings.TrimSuffix(baseName, path.Ext(baseName)),
+		"ext":       ext,
+		"content":   string(v.ContentType),
+		"media":     media,
+		"alpha":     alphaBucket(g.Name.Normalized, alphaSize),
+		"region":    string(region),
+		"target":    string(g.Target),
+		"genre":     orUnknown(g.Metadata.Genre),
+		"year":      year,
+		"publisher": orUnknown(g.Metadata.Publisher),
+		"players":   orUnknown(g.Metadata.Players),
+		"port":      port,
+		"flags":     flags,
+		"variant":   fmt.Sprintf("%d", index),
+	}
+}
+
+// keptVariants counts the variants of a game that Plan will place.
+func keptVariants(g model.NormalizedGame, opts Options) int {
+	n := 0
+	for _, v := range g.Variants {
+		if !dropsVariant(g.Target, v, opts.Unsupported) {
+			n++
 		}
-		// collapse repeated spaces/dashes
-		if (r == ' ' || r == '-') && (last == ' ' || last == '-') {
-			continue
+	}
+	return n
+}
+
+// dropsVariant reports whether Plan leaves out a variant under policy.
+func dropsVariant(target model.TargetDevice, v model.NormalizedVariant, policy UnsupportedPolicy) bool {
+	if _, ok := unsupportedReason(target, v); ok {
+		return false
+	}
+	return policy == UnsupportedSkip || (policy == UnsupportedConvert && v.ContentType != model.ContentZip)
+}
+
+// Exclusions lists the variants the target device cannot use, in input order.
+// With UnsupportedSkip these are the variants Plan leaves out; with UnsupportedWarn they are
+// planned with a warning; with UnsupportedConvert they are the ones that cannot be converted.
+func Exclusions(games []model.NormalizedGame, opts Options) []Exclusion {
+	if opts.Unsupported == UnsupportedKeep {
+		return nil
+	}
+	var out []Exclusion
+	for _, g := range games {
+		for vi, v := range g.Variants {
+			reason, ok := unsupportedReason(g.Target, v)
+			if ok || (opts.Unsupported == UnsupportedConvert && v.ContentType == model.ContentZip) {
+				continue
+			}
+			out = append(out, Exclusion{
+				GameID:    g.ID,
+				VariantID: fmt.Sprintf("%s-%d", g.ID, vi),
+				Title:     g.Title,
+				Target:    g.Target,
+				Content:   v.ContentType,
+				Reason:    reason,
+			})
 		}
-		b.WriteRune(r)
-		last = r
 	}
-	return strings.Trim(b.String(), " -")
+	return out
 }
 
-func sanitizeFile(name, ext string) string {
+// unsupportedReason checks a variant's content type, and the extension of its source file when
+// it has one, against the target profile and explains a mismatch. The executor copies a source
+// under its own extension, so a tape the target only opens as t64 cannot come from a tap file.
+func unsupportedReason(target model.TargetDevice, v model.NormalizedVariant) (string, bool) {
+	profile := model.ProfileFor(target)
+	if !profile.SupportsContent(v.ContentType) {
+		return fmt.Sprintf("%s does not support %s content", target, v.ContentType), false
+	}
+	if ext := sourceExt(v); ext != "" && v.ContentType != model.ContentZip && !profile.SupportsExtension(ext) {
+		return fmt.Sprintf("%s does not open .%s files", target, ext), false
+	}
+	return "", true
+}
+
+// sourceExt returns the lowercased extension of a variant's source file, or "" without one.
+func sourceExt(v model.NormalizedVariant) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(v.SourcePath), "."))
+}
+
+func orUnknown(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return unknownGroup
+	}
+	return value
+}
+
+// nameRules decide which characters folder and file names keep, following the target profile:
+// ASCII letters and digits, or any Unicode letter or digit for the unicode charset, folded to
+// lower case only for targets that force it.
+type nameRules struct {
+	unicode bool
+	lower   bool
+}
+
+// nameRulesFor returns the naming rules of a target's profile.
+func nameRulesFor(target model.TargetDevice) nameRules {
+	profile := model.ProfileFor(target)
+	return nameRules{unicode: profile.Charset == model.CharsetUnicode, lower: profile.ForceLowercase}
+}
+
+// name cleans a folder or game name.
+func (nr nameRules) name(name string) string {
+	return nr.stem(strings.TrimSpace(name))
+}
+
+func (nr nameRules) file(name, ext string) string {
 	base := strings.TrimSpace(name)
 	actualExt := path.Ext(base)
 	base = strings.TrimSuffix(base, actualExt)
-	runes := []rune(base)
+	clean := nr.stem(base)
+	extUse := strings.ToLower(actualExt)
+	if ext != "" {
+		extUse = "." + strings.ToLower(ext)
+	}
+	if clean == "" {
+		return strings.TrimLeft(extUse, ".")
+	}
+	if extUse != "" && !strings.HasSuffix(clean, extUse) {
+		return clean + extUse
+	}
+	return clean
+}
+
+// FileName cleans a source file's name for target by the same rules as planned names, keeping
+// its extension (lowercased) and shortening the stem to the target's name limit. The executor
+// names siblings and files found in source folders and archives with it.
+func FileName(target model.TargetDevice, name string) string {
+	base := strings.TrimSpace(name)
+	ext := path.Ext(base)
+	stem := []rune(nameRulesFor(target).stem(strings.TrimSuffix(base, ext)))
+	if limit := model.ProfileFor(target).MaxNameLen; limit > 0 && len(stem) > limit {
+		stem = stem[:limit]
+	}
+	return strings.TrimRight(string(stem), " -") + strings.ToLower(ext)
+}
+
+// stem cleans a file name without its extension.
+func (nr nameRules) stem(base string) string {
 	var b strings.Builder
 	last := rune(0)
-	for _, r := range runes {
+	for _, r := range base {
+		if nr.lower {
+			r = unicode.ToLower(r)
+		}
 		switch {
 		case r == '_':
 			r = ' '
 		case r == '\'':
 			continue
-		case (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == ' ':
-			if r >= 'A' && r <= 'Z' {
-				r = r - 'A' + 'a'
-			}
+		case r == ' ' || (r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))):
+			// keep
+		case nr.unicode && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			// keep
 		default:
 			r = '-'
 		}
+		// collapse repeated spaces/dashes
 		if (r == ' ' || r == '-') && (last == ' ' || last == '-') {
 			continue
 		}
 		b.WriteRune(r)
 		last = r
 	}
-	clean := strings.Trim(b.String(), " -")
-	extUse := strings.ToLower(actualExt)
-	if ext != "" {
-		extUse = "." + strings.ToLower(ext)
-	}
-	if clean == "" {
-		return strings.TrimLeft(extUse, ".")
-	}
-	if extUse != "" && !strings.HasSuffix(clean, extUse) {
-		return clean + extUse
-	}
-	return clean
+	return strings.Trim(b.String(), " -")
 }
 
 func sanitizePathPart(part string) string {
@@ -176,6 +391,29 @@ func extensionForContent(ct model.ContentType) string {
 	}
 }
 
+// plannedExtension returns the extension a variant is planned with: its source file's own
+// extension when the target opens it, since that is the file copied, otherwise the extension
+// the target uses for the content type, e.g. "t64" for tapes on a device that opens no "tap"
+// files, falling back to the content's usual extension.
+func plannedExtension(target model.TargetDevice, v model.NormalizedVariant) string {
+	profile := model.ProfileFor(target)
+	if ext := sourceExt(v); ext != "" && slices.Contains(v.ContentType.Extensions(), ext) && profile.SupportsExtension(ext) {
+		return ext
+	}
+	if ext := profile.ExtensionFor(v.ContentType); ext != "" {
+		return ext
+	}
+	return extensionForContent(v.ContentType)
+}
+
+// variantExt returns the extension a variant's content type maps to, falling back to its source file.
+func variantExt(v model.NormalizedVariant) string {
+	if ext := extensionForContent(v.ContentType); ext != "" {
+		return ext
+	}
+	return strings.ToLower(strings.TrimPrefix(path.Ext(v.SourcePath), "."))
+}
+
 func alphaBucket(name string, size int) string {
 	clean := strings.ToLower(strings.TrimSpace(name))
 	if clean == "" {
@@ -1,8 +1,12 @@
 package layout
 
 import (
+	"fmt"
+	"path"
+	"strings"
 	"testing"
 
+	"github.com/wazp/c64dreams-tool/internal/normalize"
 	"github.com/wazp/c64dreams-tool/pkg/model"
 )
 
@@ -15,6 +19,10 @@ func TestMediaGroupFor(t *testing.T) {
 		{"d81", "disks"},
 		{"tap", "tape"},
 		{"crt", "cart"},
+		{"ef", "cart"},
+		{"PRG", "prg"},
+		{"zip", "zip"},
+		{"txt", "unknown"},
 	}
 
 	for _, tc := range cases {
@@ -48,6 +56,25 @@ func TestPlanMediaGrouping(t *testing.T) {
 	expectPath(t, planned, []string{"games/disks/jumpman/disk1.d64"})
 }
 
+func TestPlanMediaDirsRenameAndMerge(t *testing.T) {
+	games := []model.NormalizedGame{
+		sampleGame("g1", "Jumpman", "Disk1", model.ContentDisk),
+		sampleGame("g2", "Paradroid", "Cart", model.ContentCart),
+		sampleGame("g3", "Hero", "Hero", model.ContentPrg),
+	}
+	dirs := map[string]string{"disks": "DISKS", "cart": "carts", "prg": "carts"}
+	planned, err := Plan(games, Options{GroupByMedia: true, MediaDirs: dirs})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{
+		"DISKS/jumpman/disk1.d64",
+		"carts/paradroid/cart.crt",
+		"carts/hero/hero.prg",
+	})
+}
+
 func TestPlanAlphaGrouping(t *testing.T) {
 	game := sampleGame("g1", "Jumpman", "Disk1", model.ContentDisk)
 	planned, err := Plan([]model.NormalizedGame{game}, Options{BaseDir: "games", GroupByAlpha: true})
@@ -102,6 +129,423 @@ func TestPlanNumericBucket(t *testing.T) {
 	expectPath(t, planned, []string{"games/1/1942/disk1.d64"})
 }
 
+func TestPlanSkipsUnsupportedContent(t *testing.T) {
+	disk := sampleGame("g1", "Alpha", "Disk1", model.ContentDisk)
+	tape := sampleGame("g2", "Beta", "Tape", model.ContentTape)
+	games := []model.NormalizedGame{disk, tape}
+	opts := Options{BaseDir: "games", Unsupported: UnsupportedSkip}
+
+	planned, err := Plan(games, opts)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{"games/alpha/disk1.d64"})
+
+	excluded := Exclusions(games, opts)
+	if len(excluded) != 1 || excluded[0].GameID != "g2" || excluded[0].Content != model.ContentTape {
+		t.Fatalf("unexpected exclusions: %+v", excluded)
+	}
+}
+
+func TestPlanWarnsOnUnsupportedContent(t *testing.T) {
+	tape := sampleGame("g2", "Beta", "Tape", model.ContentTape)
+
+	planned, err := Plan([]model.NormalizedGame{tape}, Options{BaseDir: "games", Unsupported: UnsupportedWarn})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{"games/beta/tape.tap"})
+	if planned[0].Warning == "" {
+		t.Fatalf("expected warning on unsupported variant")
+	}
+}
+
+func TestPlanConvertsZipVariants(t *testing.T) {
+	zipped := sampleGame("g1", "Alpha", "Disk1", model.ContentZip)
+	tape := sampleGame("g2", "Beta", "Tape", model.ContentTape)
+	games := []model.NormalizedGame{zipped, tape}
+	opts := Options{BaseDir: "games", Unsupported: UnsupportedConvert}
+
+	planned, err := Plan(games, opts)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	// the zip is extracted; tapes have no conversion and are left out
+	expectPath(t, planned, []string{"games/alpha/disk1.zip"})
+	if !planned[0].Extract {
+		t.Fatalf("expected the zip variant to be extracted")
+	}
+	excluded := Exclusions(games, opts)
+	if len(excluded) != 1 || excluded[0].GameID != "g2" {
+		t.Fatalf("unexpected exclusions: %+v", excluded)
+	}
+}
+
+func TestPlanUsesExtensionTheTargetOpens(t *testing.T) {
+	tape := sampleGame("g1", "Beta", "Tape", model.ContentTape)
+	tape.Target = model.TargetKungFuFlash
+
+	planned, err := Plan([]model.NormalizedGame{tape}, Options{BaseDir: "games", Unsupported: UnsupportedSkip})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	// KungFuFlash loads tapes from t64 images only
+	expectPath(t, planned, []string{"games/beta/tape.t64"})
+}
+
+func TestPlanLeavesOutSourcesTheTargetCannotOpen(t *testing.T) {
+	tap := sampleGame("g1", "Beta", "Tape", model.ContentTape)
+	tap.Variants[0].SourcePath = "Beta/Beta.tap"
+	t64 := sampleGame("g2", "Gamma", "Tape", model.ContentTape)
+	t64.Variants[0].SourcePath = "Gamma/Gamma.T64"
+	games := []model.NormalizedGame{tap, t64}
+	for i := range games {
+		games[i].Target = model.TargetKungFuFlash
+	}
+	opts := Options{BaseDir: "games", Unsupported: UnsupportedSkip}
+
+	planned, err := Plan(games, opts)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	// KungFuFlash opens tapes, but not tap files, and a source is copied as it is
+	expectPath(t, planned, []string{"games/gamma/gamma.t64"})
+	excluded := Exclusions(games, opts)
+	if len(excluded) != 1 || excluded[0].GameID != "g1" || !strings.Contains(excluded[0].Reason, ".tap") {
+		t.Fatalf("unexpected exclusions: %+v", excluded)
+	}
+}
+
+func TestPlanFilenameFlags(t *testing.T) {
+	game := sampleGame("g1", "Paradroid", "Disk1", model.ContentDisk)
+	game.Target = model.TargetTheC64
+	game.Variants[0].Region = model.RegionNTSC
+	game.Variants[0].Settings = model.Settings{JoystickPort: 1, TrueDrive: true}
+
+	planned, err := Plan([]model.NormalizedGame{game}, Options{GroupByAlpha: true, FilenameFlags: true})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	// TheC64 does not force lower case, so names keep theirs
+	expectPath(t, planned, []string{"p/Paradroid/Disk1_J1_NTSC_TDE.d64"})
+	if planned[0].Source != "Paradroid/Disk1.d64" {
+		t.Fatalf("flags should not leak into the source path, got %s", planned[0].Source)
+	}
+}
+
+func TestPlanFollowsProfileCharsetAndCase(t *testing.T) {
+	target := model.TargetDevice("unicode-layout-test")
+	if err := model.RegisterProfile(model.TargetProfile{Target: target, MaxNameLen: 64, Charset: model.CharsetUnicode}); err != nil {
+		t.Fatalf("RegisterProfile returned error: %v", err)
+	}
+	game := model.Game{ID: "g1", Title: "Söldner: Zürich", Variants: []model.Variant{{Label: "Disk 1", ContentType: model.ContentDisk}}}
+
+	cases := []struct {
+		target model.TargetDevice
+		want   string
+	}{
+		{target, "Söldner Zürich/Disk 1.d64"},
+		{model.TargetSD2IEC, "s ldner z rich/disk 1.d64"},
+	}
+	for _, tc := range cases {
+		ng, err := normalize.NormalizeGame(game, normalize.Options{Target: tc.target})
+		if err != nil {
+			t.Fatalf("NormalizeGame returned error: %v", err)
+		}
+		planned, err := Plan([]model.NormalizedGame{ng}, Options{})
+		if err != nil {
+			t.Fatalf("Plan returned error: %v", err)
+		}
+		expectPath(t, planned, []string{tc.want})
+	}
+}
+
+func TestFileNameFollowsProfile(t *testing.T) {
+	target := model.TargetDevice("unicode-file-test")
+	if err := model.RegisterProfile(model.TargetProfile{Target: target, MaxNameLen: 64, Charset: model.CharsetUnicode}); err != nil {
+		t.Fatalf("RegisterProfile returned error: %v", err)
+	}
+
+	cases := []struct {
+		target model.TargetDevice
+		name   string
+		want   string
+	}{
+		{model.TargetUltimate, "Disk1_J1_NTSC_TDE.D64", "Disk1 J1 NTSC TDE.d64"},
+		{model.TargetSD2IEC, "Disk1_J1_NTSC_TDE.D64", "disk1 j1 ntsc td.d64"},
+		{target, "Söldner Zürich.d64", "Söldner Zürich.d64"},
+		{model.TargetUltimate, "Söldner Zürich.d64", "S-ldner Z-rich.d64"},
+	}
+	for _, tc := range cases {
+		if got := FileName(tc.target, tc.name); got != tc.want {
+			t.Fatalf("FileName(%s, %q) = %q, want %q", tc.target, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPlanStackedGroupings(t *testing.T) {
+	game := sampleGame("g1", "Jumpman", "Disk1", model.ContentDisk)
+	game.Metadata = model.Metadata{Genre: "Platform / Puzzle", Year: "c. 1983"}
+	game.Variants[0].Settings.JoystickPort = 2
+	other := sampleGame("g2", "Alpha", "Disk1", model.ContentDisk)
+
+	opts := Options{BaseDir: "games", GroupBy: []Grouping{GroupGenre, GroupYear, GroupJoystickPort, GroupMedia, GroupLetter}}
+	planned, err := Plan([]model.NormalizedGame{game, other}, opts)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{
+		"games/platform puzzle/1983/port2/disks/j/jumpman/disk1.d64",
+		"games/unknown/unknown/unknown/disks/a/alpha/disk1.d64",
+	})
+}
+
+func TestParseGroupings(t *testing.T) {
+	groupings, err := ParseGroupings([]string{"media", " Letter "})
+	if err != nil {
+		t.Fatalf("ParseGroupings returned error: %v", err)
+	}
+	if len(groupings) != 2 || groupings[0] != GroupMedia || groupings[1] != GroupLetter {
+		t.Fatalf("unexpected groupings: %v", groupings)
+	}
+
+	if groupings, err := ParseGroupings([]string{"none"}); err != nil || groupings != nil {
+		t.Fatalf("expected none to yield no grouping, got %v (%v)", groupings, err)
+	}
+	for _, bad := range [][]string{{"color"}, {"letter", "letter"}, {"none", "letter"}} {
+		if _, err := ParseGroupings(bad); err == nil {
+			t.Fatalf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestOptionsMetadataFields(t *testing.T) {
+	opts := Options{GroupBy: []Grouping{GroupYear, GroupLetter, GroupGenre}, PathTemplate: "{players}/{name}.{ext}"}
+	if got := strings.Join(opts.MetadataFields(), ","); got != "genre,year,players" {
+		t.Fatalf("unexpected metadata fields: %s", got)
+	}
+	if got := (Options{GroupByMedia: true, GroupByAlpha: true}).MetadataFields(); len(got) != 0 {
+		t.Fatalf("media and letter read no metadata, got %v", got)
+	}
+}
+
+func TestPlanSplitsOverfullDirectories(t *testing.T) {
+	names := []string{"Aardvark", "Abacus", "Acorn", "Amiga", "Anagram", "Antics", "Azimuth", "Boulder"}
+	var games []model.NormalizedGame
+	for _, n := range names {
+		games = append(games, sampleGame(n, n, "Disk1", model.ContentDisk))
+	}
+
+	planned, err := Plan(games, Options{GroupByAlpha: true, MaxEntriesPerDir: 3})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{
+		"a/aa-ac/aardvark/disk1.d64",
+		"a/aa-ac/abacus/disk1.d64",
+		"a/aa-ac/acorn/disk1.d64",
+		"a/am-ana/amiga/disk1.d64",
+		"a/am-ana/anagram/disk1.d64",
+		"a/ant-az/antics/disk1.d64",
+		"a/ant-az/azimuth/disk1.d64",
+		"b/boulder/disk1.d64",
+	})
+}
+
+func TestPlanSplitNestsWhenRangesOverflow(t *testing.T) {
+	var games []model.NormalizedGame
+	for _, n := range []string{"aa", "ab", "ac", "ad", "ae", "af", "ag", "ah", "ai"} {
+		games = append(games, sampleGame(n, n, "Disk1", model.ContentDisk))
+	}
+
+	planned, err := Plan(games, Options{MaxEntriesPerDir: 2})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	children := map[string]map[string]struct{}{}
+	for _, p := range planned {
+		parts := strings.Split(path.Dir(p.Path), "/")
+		if len(parts) < 3 {
+			t.Fatalf("expected nested range folders, got %s", p.Path)
+		}
+		for i := 1; i < len(parts); i++ {
+			parent := strings.Join(parts[:i], "/")
+			if children[parent] == nil {
+				children[parent] = map[string]struct{}{}
+			}
+			children[parent][parts[i]] = struct{}{}
+		}
+	}
+	for dir, kids := range children {
+		if len(kids) > 2 {
+			t.Fatalf("directory %s holds %d entries", dir, len(kids))
+		}
+	}
+}
+
+func TestPlanSplitLabelsFitNameLimit(t *testing.T) {
+	var games []model.NormalizedGame
+	for _, n := range []string{"adventure quest alpha", "adventure quest bravo", "adventure quest charlie", "adventure quest delta", "adventure quest echo", "adventure quest foxtrot"} {
+		games = append(games, sampleGame(n, n, "Disk1", model.ContentDisk))
+	}
+
+	// sd2iec names are at most 16 characters, so "adventure quest a-adventure quest c" would not fit
+	planned, err := Plan(games, Options{MaxEntriesPerDir: 2})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{
+		"a-c/a-b/adventure quest alpha/disk1.d64",
+		"a-c/a-b/adventure quest bravo/disk1.d64",
+		"a-c/c/adventure quest charlie/disk1.d64",
+		"d-f/d-e/adventure quest delta/disk1.d64",
+		"d-f/d-e/adventure quest echo/disk1.d64",
+		"d-f/f/adventure quest foxtrot/disk1.d64",
+	})
+}
+
+func TestRangeLabelsStayWithinLimit(t *testing.T) {
+	chunks := [][]string{
+		{"the great escape one", "the great escape two"},
+		{"the great escapist", "the great escapology"},
+	}
+	labels := rangeLabels(chunks, 8)
+	if len(labels) != 2 || labels[0] == labels[1] {
+		t.Fatalf("expected two distinct labels, got %v", labels)
+	}
+	for _, l := range labels {
+		if len([]rune(l)) > 8 {
+			t.Fatalf("label %q is longer than 8", l)
+		}
+	}
+}
+
+func TestPlanFlatFileKeepsItsFolderFree(t *testing.T) {
+	multi := sampleGame("e1", "Elite", "Disk1", model.ContentDisk)
+	multi.Variants = append(multi.Variants, model.NormalizedVariant{
+		Label:       model.NormalizedName{Normalized: "Disk2"},
+		Region:      model.RegionBoth,
+		ContentType: model.ContentDisk,
+	})
+	games := []model.NormalizedGame{multi, sampleGame("e2", "Elite", "Disk1", model.ContentPrg)}
+
+	planned, err := Plan(games, Options{GroupByAlpha: true, Flat: true})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	// elite.prg would move into e/elite, the other game's folder, if its source had companions
+	expectPath(t, planned, []string{
+		"e/elite/disk1.d64",
+		"e/elite/disk2.d64",
+		"e/elite~1.prg",
+	})
+	if planned[2].GameDir != "e/elite~1" {
+		t.Fatalf("unexpected game dir %q", planned[2].GameDir)
+	}
+}
+
+func TestPlanNestedAlphaDepth(t *testing.T) {
+	names := []string{"Aardvark", "Abacus", "Abba", "Abbey", "Abort", "Acorn", "Bark", "Cat", "Dog", "Emu"}
+	var games []model.NormalizedGame
+	for _, n := range names {
+		games = append(games, sampleGame(n, n, "Disk1", model.ContentDisk))
+	}
+
+	planned, err := Plan(games, Options{GroupByAlpha: true, AlphaDepth: 3, ScreenEntries: 3})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{
+		"a/aa/aardvark/disk1.d64",
+		"a/ab/aba-abb/abacus/disk1.d64",
+		"a/ab/aba-abb/abba/disk1.d64",
+		"a/ab/aba-abb/abbey/disk1.d64",
+		"a/ab/abo/abort/disk1.d64",
+		"a/ac/acorn/disk1.d64",
+		"b-d/bark/disk1.d64",
+		"b-d/cat/disk1.d64",
+		"b-d/dog/disk1.d64",
+		"e/emu/disk1.d64",
+	})
+}
+
+func TestPlanNestedAlphaFitsOneScreen(t *testing.T) {
+	var games []model.NormalizedGame
+	for i := 0; i < 30; i++ {
+		name := fmt.Sprintf("a%c%c", 'a'+i/10, 'a'+i%10)
+		games = append(games, sampleGame(name, name, "Disk1", model.ContentDisk))
+	}
+
+	// the directory limit is far above one screen, so it does not decide the nesting
+	planned, err := Plan(games, Options{GroupByAlpha: true, AlphaDepth: 2, MaxEntriesPerDir: 144})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	perFolder := map[string]int{}
+	for _, p := range planned {
+		perFolder[path.Dir(path.Dir(p.Path))]++
+	}
+	for folder, n := range perFolder {
+		if n > defaultScreenEntries {
+			t.Fatalf("%s holds %d entries, more than one screen", folder, n)
+		}
+	}
+	if len(perFolder) < 2 {
+		t.Fatalf("expected the letter a to be nested, got %v", perFolder)
+	}
+}
+
+func TestPlanRejectsAlphaDepthWithTemplate(t *testing.T) {
+	games := []model.NormalizedGame{sampleGame("g1", "Game", "Disk1", model.ContentDisk)}
+	if _, err := Plan(games, Options{AlphaDepth: 2, PathTemplate: "{alpha}/{name}.{ext}"}); err == nil {
+		t.Fatalf("expected alpha depth with a path template to be rejected")
+	}
+}
+
+func TestPlanFlatSingleFileGames(t *testing.T) {
+	multi := sampleGame("m1", "Maniac Mansion", "Disk1", model.ContentDisk)
+	multi.Variants = append(multi.Variants, model.NormalizedVariant{
+		Label:       model.NormalizedName{Normalized: "Disk2"},
+		Region:      model.RegionBoth,
+		ContentType: model.ContentDisk,
+	})
+	games := []model.NormalizedGame{
+		sampleGame("b1", "Boulder", "Disk1", model.ContentPrg),
+		multi,
+		sampleGame("b2", "Boulder!", "Disk1", model.ContentPrg),
+	}
+
+	planned, err := Plan(games, Options{GroupByAlpha: true, Flat: true})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+
+	expectPath(t, planned, []string{
+		"b/boulder.prg",
+		"m/maniac mansion/disk1.d64",
+		"m/maniac mansion/disk2.d64",
+		"b/boulder~1.prg",
+	})
+	if planned[0].GameDir != "b/boulder" || planned[3].GameDir != "b/boulder~1" {
+		t.Fatalf("unexpected game dirs %q, %q", planned[0].GameDir, planned[3].GameDir)
+	}
+	if planned[1].GameDir != "" {
+		t.Fatalf("multi-file game should not be flat")
+	}
+}
+
 func sampleGame(id, name, label string, ct model.ContentType) model.NormalizedGame {
 	return model.NormalizedGame{
 		ID:     id,
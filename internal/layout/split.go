@@ -0,0 +1,212 @@
+package layout
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// placement tracks a planned path as segments plus the index of the per-game entry
+// (game folder or file) so overfull directories can be split after planning.
+type placement struct {
+	parts []string
+	entry int
+	alpha int  // index of the letter-grouping slot filled by nestAlpha, or -1
+	flat  bool // entry is a single-file game placed directly in its bucket
+}
+
+// splitOverfull inserts range folders (e.g. "aa-am", "an-az") above the game entries of any
+// directory holding more than maxEntries of them. Ranges follow the real name distribution and
+// nest further when one level of ranges would itself be overfull. Range folder names stay within
+// the target's name limit.
+func splitOverfull(places []placement, planned []PlannedFile, maxEntries int) {
+	if maxEntries <= 0 {
+		return
+	}
+	if maxEntries < 2 {
+		maxEntries = 2
+	}
+
+	groups := make(map[string][]int)
+	for i, pl := range places {
+		key := strings.Join(pl.parts[:pl.entry], "/")
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, members := range groups {
+		seen := make(map[string]struct{})
+		var names []string
+		limit := 0
+		for _, i := range members {
+			name := places[i].parts[places[i].entry]
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+			if l := model.ProfileFor(planned[i].Target).MaxNameLen; l > 0 && (limit == 0 || l < limit) {
+				limit = l
+			}
+		}
+		if len(names) <= maxEntries {
+			continue
+		}
+		sort.Slice(names, func(a, b int) bool { return strings.ToLower(names[a]) < strings.ToLower(names[b]) })
+
+		levels := rangeLevels(names, maxEntries, limit)
+		for _, i := range members {
+			pl := &places[i]
+			extra := levels[pl.parts[pl.entry]]
+			parts := make([]string, 0, len(pl.parts)+len(extra))
+			parts = append(parts, pl.parts[:pl.entry]...)
+			parts = append(parts, extra...)
+			parts = append(parts, pl.parts[pl.entry:]...)
+			pl.parts = parts
+			pl.entry += len(extra)
+		}
+	}
+}
+
+// rangeLevels assigns each sorted name the range folders it belongs under so that no folder
+// holds more than maxEntries children. Labels are at most limit runes long; zero means no limit.
+func rangeLevels(names []string, maxEntries, limit int) map[string][]string {
+	out := make(map[string][]string, len(names))
+	var walk func(names []string, prefix []string)
+	walk = func(names []string, prefix []string) {
+		if len(names) <= maxEntries {
+			for _, n := range names {
+				out[n] = prefix
+			}
+			return
+		}
+		count := (len(names) + maxEntries - 1) / maxEntries
+		if count > maxEntries {
+			count = maxEntries
+		}
+		chunks := balancedChunks(names, count)
+		labels := rangeLabels(chunks, limit)
+		for i, chunk := range chunks {
+			next := append(append([]string(nil), prefix...), labels[i])
+			walk(chunk, next)
+		}
+	}
+	walk(names, nil)
+	return out
+}
+
+// balancedChunks splits sorted names into count contiguous chunks whose sizes differ by at most one.
+func balancedChunks(names []string, count int) [][]string {
+	chunks := make([][]string, 0, count)
+	size, extra := len(names)/count, len(names)%count
+	start := 0
+	for i := 0; i < count; i++ {
+		end := start + size
+		if i < extra {
+			end++
+		}
+		chunks = append(chunks, names[start:end])
+		start = end
+	}
+	return chunks
+}
+
+// rangeLabels names each chunk by the shortest prefixes that tell it apart from its neighbours,
+// cut down to limit runes when names share long prefixes. Labels that end up equal get "~1",
+// "~2", ... suffixes, also within limit.
+func rangeLabels(chunks [][]string, limit int) []string {
+	first := chunks[0][0]
+	lastChunk := chunks[len(chunks)-1]
+	last := lastChunk[len(lastChunk)-1]
+	// outer bounds use one character past what every name in the directory shares
+	outer := commonPrefixLen(strings.ToLower(first), strings.ToLower(last)) + 1
+
+	labels := make([]string, len(chunks))
+	seen := make(map[string]struct{})
+	for i, chunk := range chunks {
+		start := runePrefix(chunk[0], outer)
+		if i > 0 {
+			prev := chunks[i-1]
+			start = distinguishingPrefix(chunk[0], prev[len(prev)-1])
+		}
+		end := runePrefix(chunk[len(chunk)-1], outer)
+		if i < len(chunks)-1 {
+			end = distinguishingPrefix(chunk[len(chunk)-1], chunks[i+1][0])
+		}
+
+		base := fitRange(start, end, outer-1, limit)
+		label := base
+		for n := 1; ; n++ {
+			if _, dup := seen[label]; !dup {
+				break
+			}
+			label = SuffixStem(base, n, limit)
+		}
+		seen[label] = struct{}{}
+		labels[i] = label
+	}
+	return labels
+}
+
+// fitRange joins range bounds as "start-end", or just start when they are equal, within limit
+// runes. A label that is too long first drops the shared runes every name in the directory
+// starts with, since they tell no range apart, then shortens both bounds evenly.
+func fitRange(start, end string, shared, limit int) string {
+	label := joinRange(start, end)
+	if limit <= 0 || len([]rune(label)) <= limit {
+		return label
+	}
+	s, e := dropRunes(start, shared), dropRunes(end, shared)
+	if s != "" && e != "" {
+		start, end = s, e
+		if label = joinRange(start, end); len([]rune(label)) <= limit {
+			return label
+		}
+	}
+	if start == end || limit < 3 {
+		return runePrefix(start, limit)
+	}
+	keep := (limit - 1) / 2
+	return runePrefix(start, keep) + "-" + runePrefix(end, limit-1-keep)
+}
+
+func joinRange(start, end string) string {
+	if start == end {
+		return start
+	}
+	return start + "-" + end
+}
+
+// dropRunes removes the first n runes of s and any spaces or dashes that follow.
+func dropRunes(s string, n int) string {
+	runes := []rune(s)
+	if n >= len(runes) {
+		return ""
+	}
+	return strings.TrimLeft(string(runes[n:]), " -")
+}
+
+// distinguishingPrefix returns the shortest prefix of s that differs from other.
+func distinguishingPrefix(s, other string) string {
+	return runePrefix(s, commonPrefixLen(strings.ToLower(s), strings.ToLower(other))+1)
+}
+
+func runePrefix(s string, n int) string {
+	runes := []rune(strings.ToLower(s))
+	if n > len(runes) {
+		n = len(runes)
+	}
+	prefix := strings.TrimRight(string(runes[:n]), " -")
+	if prefix == "" {
+		return "misc"
+	}
+	return prefix
+}
+
+func commonPrefixLen(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	n := 0
+	for n < len(ra) && n < len(rb) && ra[n] == rb[n] {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,269 @@
+package layout
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Template is a parsed path template such as "{media}/{alpha:2}/{name}/{label}.{ext}".
+//
+// Placeholders name a game or variant field, optionally followed by an argument after ':'
+// and filters after '|', e.g. "{title|slug}" or "{name|upper}". Without a sanitizer filter
+// (name, file, slug, raw) each field goes through its default sanitizer.
+type Template struct {
+	raw      string
+	segments [][]templateToken
+}
+
+type templateToken struct {
+	literal string
+	field   string
+	arg     int
+	filters []string
+}
+
+// templateFields maps each placeholder to the sanitizer applied when no sanitizer filter is given.
+var templateFields = map[string]string{
+	"id":        "raw",
+	"title":     "name",
+	"name":      "name",
+	"label":     "name",
+	"file":      "file",
+	"ext":       "raw",
+	"content":   "raw",
+	"media":     "raw",
+	"alpha":     "raw",
+	"region":    "raw",
+	"target":    "raw",
+	"genre":     "name",
+	"year":      "raw",
+	"publisher": "name",
+	"players":   "name",
+	"port":      "raw",
+	"flags":     "raw",
+	"variant":   "raw",
+}
+
+var templateSanitizers = map[string]func(nameRules, string) string{
+	"raw":  func(_ nameRules, s string) string { return s },
+	"name": nameRules.name,
+	"file": nameRules.stem,
+	"slug": func(_ nameRules, s string) string { return slugValue(s) },
+}
+
+var templateCaseFilters = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// ParseTemplate validates a path template. Templates must be relative, must not step outside
+// the output with "..", and must end in a file name with an extension.
+func ParseTemplate(raw string) (*Template, error) {
+	clean := strings.ReplaceAll(strings.TrimSpace(raw), "\\", "/")
+	if clean == "" {
+		return nil, fmt.Errorf("path template is empty")
+	}
+	if strings.HasPrefix(clean, "/") || (len(clean) > 1 && clean[1] == ':') {
+		return nil, fmt.Errorf("path template %q must be relative", raw)
+	}
+
+	parts := strings.Split(clean, "/")
+	t := &Template{raw: raw}
+	for i, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return nil, fmt.Errorf("path template %q has an empty, '.' or '..' segment", raw)
+		}
+		tokens, err := parseSegment(part)
+		if err != nil {
+			return nil, fmt.Errorf("path template %q: %w", raw, err)
+		}
+		if i == len(parts)-1 && !endsWithExtension(tokens) {
+			return nil, fmt.Errorf("path template %q must end with a file name and extension, e.g. {file}.{ext}", raw)
+		}
+		t.segments = append(t.segments, tokens)
+	}
+	return t, nil
+}
+
+// String returns the template as written.
+func (t *Template) String() string {
+	return t.raw
+}
+
+// uses reports whether any placeholder references a field.
+func (t *Template) uses(field string) bool {
+	for _, seg := range t.segments {
+		for _, tok := range seg {
+			if tok.field == field {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// entrySegment returns the index of the segment that names the game (the first using name, title
+// or id), or the file segment when none does. Directory splitting inserts range folders above it.
+func (t *Template) entrySegment() int {
+	for i, seg := range t.segments {
+		for _, tok := range seg {
+			switch tok.field {
+			case "name", "title", "id":
+				return i
+			}
+		}
+	}
+	return len(t.segments) - 1
+}
+
+// expand renders the template into path segments for one variant.
+func (t *Template) expand(values map[string]string, alphaName string, rules nameRules) ([]string, error) {
+	out := make([]string, 0, len(t.segments))
+	for _, seg := range t.segments {
+		var b strings.Builder
+		for _, tok := range seg {
+			if tok.field == "" {
+				b.WriteString(tok.literal)
+				continue
+			}
+			value := values[tok.field]
+			if tok.field == "alpha" && tok.arg > 0 {
+				value = alphaBucket(alphaName, tok.arg)
+			}
+			b.WriteString(applyFilters(tok, value, rules))
+		}
+		// FAT does not allow trailing dots or spaces, e.g. from an empty {ext}
+		part := strings.TrimRight(strings.TrimSpace(b.String()), ". ")
+		if part == "" {
+			return nil, fmt.Errorf("path template %q produced an invalid segment %q", t.raw, part)
+		}
+		out = append(out, part)
+	}
+	return out, nil
+}
+
+func applyFilters(tok templateToken, value string, rules nameRules) string {
+	sanitized := false
+	for _, f := range tok.filters {
+		if sanitize, ok := templateSanitizers[f]; ok {
+			value = sanitize(rules, value)
+			sanitized = true
+		}
+	}
+	if !sanitized {
+		value = templateSanitizers[templateFields[tok.field]](rules, value)
+	}
+	for _, f := range tok.filters {
+		if apply, ok := templateCaseFilters[f]; ok {
+			value = apply(value)
+		}
+	}
+	// values never introduce extra path segments or characters FAT rejects, even with raw
+	return pathUnsafe.Replace(value)
+}
+
+// pathUnsafe replaces path separators and the characters FAT does not allow in names.
+var pathUnsafe = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "?", "-", "*", "-", `"`, "-", "<", "-", ">", "-", "|", "-")
+
+func parseSegment(part string) ([]templateToken, error) {
+	var tokens []templateToken
+	for part != "" {
+		open := strings.IndexByte(part, '{')
+		if closeIdx := strings.IndexByte(part, '}'); closeIdx >= 0 && (open < 0 || closeIdx < open) {
+			return nil, fmt.Errorf("unexpected '}'")
+		}
+		if open < 0 {
+			tokens = append(tokens, templateToken{literal: part})
+			break
+		}
+		if open > 0 {
+			tokens = append(tokens, templateToken{literal: part[:open]})
+		}
+		end := strings.IndexByte(part[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed '{'")
+		}
+		tok, err := parsePlaceholder(part[open+1 : open+end])
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, tok)
+		part = part[open+end+1:]
+	}
+	return tokens, nil
+}
+
+func parsePlaceholder(body string) (templateToken, error) {
+	pieces := strings.Split(body, "|")
+	field := strings.TrimSpace(pieces[0])
+	tok := templateToken{}
+
+	if name, arg, ok := strings.Cut(field, ":"); ok {
+		if name != "alpha" {
+			return tok, fmt.Errorf("placeholder {%s} does not take an argument", name)
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return tok, fmt.Errorf("placeholder {%s} needs a positive bucket size", body)
+		}
+		field, tok.arg = name, n
+	}
+	if _, ok := templateFields[field]; !ok {
+		return tok, fmt.Errorf("unknown placeholder {%s}", field)
+	}
+	tok.field = field
+
+	for _, f := range pieces[1:] {
+		f = strings.TrimSpace(f)
+		_, sanitizer := templateSanitizers[f]
+		_, caseFilter := templateCaseFilters[f]
+		if !sanitizer && !caseFilter {
+			return tok, fmt.Errorf("unknown filter %q in {%s}", f, body)
+		}
+		tok.filters = append(tok.filters, f)
+	}
+	return tok, nil
+}
+
+// endsWithExtension checks that the final segment has a name part followed by ".{ext}" or a literal extension.
+func endsWithExtension(tokens []templateToken) bool {
+	n := len(tokens)
+	if n == 0 {
+		return false
+	}
+	last := tokens[n-1]
+	switch {
+	case last.field == "ext":
+		if n < 2 || tokens[n-2].field != "" || !strings.HasSuffix(tokens[n-2].literal, ".") {
+			return false
+		}
+		return n > 2 || len(tokens[n-2].literal) > 1
+	case last.field == "":
+		ext := path.Ext(last.literal)
+		if ext == "" || ext == "." {
+			return false
+		}
+		return n > 1 || len(last.literal) > len(ext)
+	default:
+		return false
+	}
+}
+
+func slugValue(s string) string {
+	var b strings.Builder
+	last := rune(0)
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			last = r
+			continue
+		}
+		if last != '-' && b.Len() > 0 {
+			b.WriteRune('-')
+			last = '-'
+		}
+	}
+	return strings.TrimRight(b.String(), "-")
+}
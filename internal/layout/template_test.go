@@ -0,0 +1,91 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestPlanPathTemplate(t *testing.T) {
+	game := sampleGame("jumpman", "Jumpman Junior", "Disk 1", model.ContentDisk)
+	game.Metadata.Genre = "Platform"
+	game.Variants[0].SourcePath = "Jumpman_Jr.d64"
+
+	cases := []struct {
+		template string
+		expected string
+	}{
+		{"{media}/{alpha:2}/{name}/{label}.{ext}", "games/disks/js-jx/jumpman junior/disk 1.d64"},
+		{"{alpha}/{file}.{ext}", "games/j/jumpman jr.d64"},
+		{"{genre|upper}/{title|slug}-{id}.{ext}", "games/PLATFORM/jumpman-junior-jumpman.d64"},
+		{"{name}.prg", "games/jumpman junior.prg"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.template, func(t *testing.T) {
+			planned, err := Plan([]model.NormalizedGame{game}, Options{BaseDir: "games", PathTemplate: tc.template, GroupByAlpha: true})
+			if err != nil {
+				t.Fatalf("Plan returned error: %v", err)
+			}
+			expectPath(t, planned, []string{tc.expected})
+		})
+	}
+}
+
+func TestParseTemplateRejectsInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		"/abs/{name}.{ext}",
+		"C:/games/{name}.{ext}",
+		"../{name}.{ext}",
+		"{media}//{name}.{ext}",
+		"{media}/{name}",
+		"{media}/{name}/",
+		"{media}/.{ext}",
+		"{colour}/{name}.{ext}",
+		"{name|shout}.{ext}",
+		"{name:2}.{ext}",
+		"{alpha:0}/{name}.{ext}",
+		"{name.{ext}",
+		"name}.{ext}",
+	}
+
+	for _, tmpl := range cases {
+		if _, err := ParseTemplate(tmpl); err == nil {
+			t.Fatalf("expected %q to be rejected", tmpl)
+		}
+	}
+}
+
+func TestTemplateValuesCannotAddSegments(t *testing.T) {
+	game := sampleGame("g1", "AC/DC", "Disk1", model.ContentDisk)
+
+	planned, err := Plan([]model.NormalizedGame{game}, Options{PathTemplate: "{title|raw}.{ext}"})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	expectPath(t, planned, []string{"AC-DC.d64"})
+}
+
+func TestTemplateRawValuesAreFATSafe(t *testing.T) {
+	game := sampleGame("g1", `Who? "Me" <Yes>: A*B|C`, "Disk1", model.ContentDisk)
+	game.Target = model.TargetUltimate
+
+	planned, err := Plan([]model.NormalizedGame{game}, Options{PathTemplate: "{title|raw}.{ext}"})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	expectPath(t, planned, []string{"Who- -Me- -Yes-- A-B-C.d64"})
+}
+
+func TestTemplateEmptyExtUsesSourceExtension(t *testing.T) {
+	game := sampleGame("g1", "Game", "Disk1", model.ContentUnknown)
+	game.Variants[0].SourcePath = "Game/Game.D81"
+
+	planned, err := Plan([]model.NormalizedGame{game}, Options{PathTemplate: "{media}/{name}.{ext}"})
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	// unknown content has no extension of its own, so the source's decides the name and media
+	expectPath(t, planned, []string{"disks/game.d81"})
+}
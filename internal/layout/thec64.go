@@ -0,0 +1,44 @@
+package layout
+
+import (
+	"path"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// TheC64 reads per-game settings from underscore flags at the end of the file name,
+// e.g. "paradroid_J1_TDE.d64".
+const (
+	theC64FlagPort1     = "_J1"
+	theC64FlagPort2     = "_J2"
+	theC64FlagNTSC      = "_NTSC"
+	theC64FlagTrueDrive = "_TDE"
+)
+
+// theC64Flags builds the filename flags for a variant's launch settings.
+func theC64Flags(settings model.Settings, region model.Region) string {
+	var b strings.Builder
+	switch settings.JoystickPort {
+	case 1:
+		b.WriteString(theC64FlagPort1)
+	case 2:
+		b.WriteString(theC64FlagPort2)
+	}
+	if region == model.RegionNTSC {
+		b.WriteString(theC64FlagNTSC)
+	}
+	if settings.TrueDrive {
+		b.WriteString(theC64FlagTrueDrive)
+	}
+	return b.String()
+}
+
+// withFlags inserts filename flags between a sanitized file name and its extension.
+func withFlags(fileName, flags string) string {
+	if flags == "" {
+		return fileName
+	}
+	ext := path.Ext(fileName)
+	return strings.TrimSuffix(fileName, ext) + flags + ext
+}
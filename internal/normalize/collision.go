@@ -8,18 +8,30 @@ import (
 )
 
 // ResolveCollisions applies deterministic suffixes to a set of normalized games using the provided options.
+// Names are compared within the target's menu-visible prefix, so titles that would look identical in the
+// menu are suffixed inside that prefix rather than past it.
 func ResolveCollisions(games []model.NormalizedGame, opts Options) []model.NormalizedGame {
-	return resolveCollisions(games, opts.EffectiveMaxLen())
+	return resolveCollisions(games, opts.EffectiveMaxLen(), opts.EffectiveDisplayLen())
 }
 
-// resolveCollisions applies deterministic suffixing to conflicting normalized names.
-func resolveCollisions(games []model.NormalizedGame, maxLen int) []model.NormalizedGame {
-	groups := make(map[string][]*model.NormalizedName)
+// resolveCollisions applies deterministic suffixing to conflicting normalized names. Every
+// suffixed name is checked against all names kept so far, so a suffix never creates a new clash;
+// the suffix number grows until the name is free.
+func resolveCollisions(games []model.NormalizedGame, maxLen, displayLen int) []model.NormalizedGame {
+	keyOf := func(e collisionEntry, name string) string {
+		return collisionKey(e.device, e.region, displayPrefix(name, displayLen))
+	}
 
+	groups := make(map[string][]collisionEntry)
+	var order []string
 	add := func(device model.TargetDevice, region model.Region, name *model.NormalizedName) {
-		key := collisionKey(device, region, name.Normalized)
+		e := collisionEntry{device: device, region: region, name: name}
+		key := keyOf(e, name.Normalized)
 		name.CollisionGroup = key
-		groups[key] = append(groups[key], name)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], e)
 	}
 
 	for i := range games {
@@ -29,33 +41,77 @@ func resolveCollisions(games []model.NormalizedGame, maxLen int) []model.Normali
 		}
 	}
 
-	for _, entries := range groups {
+	limit := maxLen
+	if displayLen > 0 && (limit <= 0 || displayLen < limit) {
+		limit = displayLen
+	}
+
+	// the first name of every group keeps its name
+	taken := make(map[string]bool, len(groups))
+	for key := range groups {
+		taken[key] = true
+	}
+
+	for _, key := range order {
+		entries := groups[key]
 		if len(entries) <= 1 {
 			continue
 		}
-		for idx, name := range entries {
+		for idx, e := range entries {
+			name := e.name
 			name.Collision = true
+			name.DisplayCollision = !hasFullNameTwin(entries, idx)
 			name.CollisionIndex = idx
-			if idx == 0 {
-				continue
-			}
-			suffix := fmt.Sprintf("~%d", idx)
+		}
+		for idx, e := range entries[1:] {
+			name := e.name
 			base := name.Normalized
-			safeLen := maxLen - len([]rune(suffix))
-			if safeLen < 0 {
-				safeLen = 0
+			for n := idx + 1; ; n++ {
+				candidate := withSuffix(base, fmt.Sprintf("~%d", n), limit)
+				if k := keyOf(e, candidate); !taken[k] {
+					taken[k] = true
+					name.Normalized = candidate
+					break
+				}
 			}
-			runes := []rune(base)
-			if len(runes) > safeLen {
-				base = string(runes[:safeLen])
-			}
-			name.Normalized = base + suffix
+			name.DisplayTruncated = displayLen > 0 && len([]rune(name.Normalized)) > displayLen
 		}
 	}
 
 	return games
 }
 
+// withSuffix appends suffix to base, shortening base so the result fits in limit runes.
+func withSuffix(base, suffix string, limit int) string {
+	safeLen := limit - len([]rune(suffix))
+	if safeLen < 0 {
+		safeLen = 0
+	}
+	runes := []rune(base)
+	if limit > 0 && len(runes) > safeLen {
+		base = strings.TrimRight(string(runes[:safeLen]), " ")
+	}
+	return base + suffix
+}
+
+// collisionEntry is a name taking part in collision checks, with the menu it appears in.
+type collisionEntry struct {
+	device model.TargetDevice
+	region model.Region
+	name   *model.NormalizedName
+}
+
+// hasFullNameTwin reports whether another entry in a collision group has the same full name as
+// entries[idx], as opposed to a name that only matches within the menu-visible prefix.
+func hasFullNameTwin(entries []collisionEntry, idx int) bool {
+	for i, e := range entries {
+		if i != idx && strings.EqualFold(e.name.Normalized, entries[idx].name.Normalized) {
+			return true
+		}
+	}
+	return false
+}
+
 func collisionKey(device model.TargetDevice, region model.Region, name string) string {
 	return strings.ToLower(string(device) + "|" + string(region) + "|" + name)
 }
@@ -10,6 +10,8 @@ import (
 
 var punctuationRegexp = regexp.MustCompile(`[^A-Za-z0-9\s]+`)
 
+var unicodePunctuationRegexp = regexp.MustCompile(`[^\p{L}\p{N}\s]+`)
+
 var stopWords = map[string]struct{}{
 	"the": {}, "of": {}, "and": {}, "a": {}, "an": {}, "for": {}, "to": {}, "in": {}, "on": {}, "at": {}, "by": {}, "with": {}, "from": {},
 }
@@ -32,16 +34,22 @@ func NormalizeGame(game model.Game, opts Options) (model.NormalizedGame, error)
 	profile := model.ProfileFor(opts.Target)
 
 	ng := model.NormalizedGame{
-		ID:     game.ID,
-		Title:  game.Title,
-		Region: game.Region,
-		Target: opts.Target,
+		ID:       game.ID,
+		Title:    game.Title,
+		Region:   game.Region,
+		Target:   opts.Target,
+		Metadata: game.Metadata,
 	}
 
-	ng.Name = normalizeName(game.Title, opts.EffectiveMaxLen())
+	displayLen := opts.EffectiveDisplayLen()
+
+	strip := stripRegexp(profile.Charset)
+
+	ng.Name = normalizeName(game.Title, opts.EffectiveMaxLen(), strip)
 	if profile.ForceLowercase {
 		ng.Name.Normalized = strings.ToLower(ng.Name.Normalized)
 	}
+	markDisplay(&ng.Name, displayLen)
 
 	for _, v := range game.Variants {
 		varRegion := v.Region
@@ -50,25 +58,27 @@ func NormalizeGame(game model.Game, opts Options) (model.NormalizedGame, error)
 		}
 
 		nv := model.NormalizedVariant{
-			Label:           normalizeName(v.Label, opts.EffectiveMaxLen()),
+			Label:           normalizeName(v.Label, opts.EffectiveMaxLen(), strip),
 			Region:          varRegion,
 			PreferredTarget: v.PreferredTarget,
 			ContentType:     v.ContentType,
 			SourcePath:      v.SourcePath,
 			Notes:           v.Notes,
+			Settings:        v.Settings,
 		}
 		if profile.ForceLowercase {
 			nv.Label.Normalized = strings.ToLower(nv.Label.Normalized)
 		}
+		markDisplay(&nv.Label, displayLen)
 		ng.Variants = append(ng.Variants, nv)
 	}
 
 	return ng, nil
 }
 
-func normalizeName(value string, maxLen int) model.NormalizedName {
+func normalizeName(value string, maxLen int, strip *regexp.Regexp) model.NormalizedName {
 	name := strings.ReplaceAll(strings.TrimSpace(value), "'", "")
-	name = punctuationRegexp.ReplaceAllString(name, " ")
+	name = strip.ReplaceAllString(name, " ")
 	name = strings.Join(strings.Fields(name), " ")
 
 	words := strings.Fields(name)
@@ -103,6 +113,28 @@ func normalizeName(value string, maxLen int) model.NormalizedName {
 	}
 }
 
+// stripRegexp selects the characters removed from names for a target charset.
+func stripRegexp(charset model.Charset) *regexp.Regexp {
+	if charset == model.CharsetUnicode {
+		return unicodePunctuationRegexp
+	}
+	return punctuationRegexp
+}
+
+// markDisplay flags names that run past the menu-visible prefix of the target.
+func markDisplay(name *model.NormalizedName, displayLen int) {
+	name.DisplayTruncated = displayLen > 0 && len([]rune(name.Normalized)) > displayLen
+}
+
+// displayPrefix returns the part of a name the target menu actually shows.
+func displayPrefix(name string, displayLen int) string {
+	runes := []rune(name)
+	if displayLen <= 0 || len(runes) <= displayLen {
+		return name
+	}
+	return string(runes[:displayLen])
+}
+
 func preserveCase(word string) string {
 	if word == "" {
 		return word
@@ -198,3 +198,136 @@ func TestCollisionResolutionAcrossTargets(t *testing.T) {
 		})
 	}
 }
+
+func TestCollisionWithinDisplayPrefix(t *testing.T) {
+	opts := Options{Target: model.TargetKungFuFlash}
+	titles := []string{
+		"Ultimate Soccer Manager Special Edition One",
+		"Ultimate Soccer Manager Special Edition Two",
+		"Jumpman",
+	}
+
+	var normalized []model.NormalizedGame
+	for i, title := range titles {
+		ng, err := NormalizeGame(model.Game{ID: fmt.Sprintf("g%d", i), Title: title}, opts)
+		if err != nil {
+			t.Fatalf("NormalizeGame returned error: %v", err)
+		}
+		normalized = append(normalized, ng)
+	}
+
+	if !normalized[0].Name.DisplayTruncated {
+		t.Fatalf("expected long name to be flagged as display truncated")
+	}
+
+	normalized = ResolveCollisions(normalized, opts)
+
+	if normalized[0].Name.Normalized != "ultimate soccer manager special edition one" {
+		t.Fatalf("expected first name unchanged, got %q", normalized[0].Name.Normalized)
+	}
+	if normalized[1].Name.Normalized != "ultimate soccer manager specia~1" {
+		t.Fatalf("expected suffix inside the menu width, got %q", normalized[1].Name.Normalized)
+	}
+	if !normalized[0].Name.DisplayCollision || !normalized[1].Name.DisplayCollision {
+		t.Fatalf("expected both names flagged as menu collisions")
+	}
+	if normalized[2].Name.Collision || normalized[2].Name.DisplayCollision {
+		t.Fatalf("expected unrelated name untouched")
+	}
+}
+
+func TestExactDuplicateIsNotDisplayCollision(t *testing.T) {
+	opts := Options{Target: model.TargetKungFuFlash}
+	var normalized []model.NormalizedGame
+	for i := 0; i < 2; i++ {
+		ng, err := NormalizeGame(model.Game{ID: fmt.Sprintf("g%d", i), Title: "Jumpman"}, opts)
+		if err != nil {
+			t.Fatalf("NormalizeGame returned error: %v", err)
+		}
+		normalized = append(normalized, ng)
+	}
+
+	normalized = ResolveCollisions(normalized, opts)
+
+	if normalized[1].Name.Normalized != "jumpman~1" {
+		t.Fatalf("unexpected suffixed name %q", normalized[1].Name.Normalized)
+	}
+	if normalized[1].Name.DisplayCollision {
+		t.Fatalf("identical names should not be reported as menu-only collisions")
+	}
+}
+
+func TestUnicodeCharsetKeepsLetters(t *testing.T) {
+	target := model.TargetDevice("unicode-test")
+	if err := model.RegisterProfile(model.TargetProfile{Target: target, MaxNameLen: 64, Charset: model.CharsetUnicode}); err != nil {
+		t.Fatalf("RegisterProfile returned error: %v", err)
+	}
+
+	ng, err := NormalizeGame(model.Game{Title: "Söldner: Zürich"}, Options{Target: target})
+	if err != nil {
+		t.Fatalf("NormalizeGame returned error: %v", err)
+	}
+	if ng.Name.Normalized != "Söldner Zürich" {
+		t.Fatalf("unexpected normalized name: %q", ng.Name.Normalized)
+	}
+}
+
+func TestCollisionLabelsEachEntry(t *testing.T) {
+	opts := Options{Target: model.TargetKungFuFlash}
+	titles := []string{
+		"Ultimate Soccer Manager Special Edition One",
+		"Ultimate Soccer Manager Special Edition One",
+		"Ultimate Soccer Manager Special Edition Two",
+	}
+	var normalized []model.NormalizedGame
+	for i, title := range titles {
+		ng, err := NormalizeGame(model.Game{ID: fmt.Sprintf("g%d", i), Title: title}, opts)
+		if err != nil {
+			t.Fatalf("NormalizeGame returned error: %v", err)
+		}
+		normalized = append(normalized, ng)
+	}
+
+	normalized = ResolveCollisions(normalized, opts)
+
+	if normalized[0].Name.DisplayCollision || normalized[1].Name.DisplayCollision {
+		t.Fatalf("exact duplicates should not be reported as menu-only collisions")
+	}
+	if !normalized[2].Name.DisplayCollision {
+		t.Fatalf("a name matching only within the menu prefix should be reported as a menu collision")
+	}
+}
+
+func TestCollisionSuffixesStayUnique(t *testing.T) {
+	opts := Options{Target: model.TargetKungFuFlash}
+	// both pairs share a 30-character start, so their suffixed names meet once shortened
+	titles := []string{
+		"Aaaaaaaaaa Bbbbbbbbbb Ccccccccdd One",
+		"Aaaaaaaaaa Bbbbbbbbbb Ccccccccdd Two",
+		"Aaaaaaaaaa Bbbbbbbbbb Ccccccccee One",
+		"Aaaaaaaaaa Bbbbbbbbbb Ccccccccee Two",
+	}
+	var normalized []model.NormalizedGame
+	for i, title := range titles {
+		ng, err := NormalizeGame(model.Game{ID: fmt.Sprintf("g%d", i), Title: title}, opts)
+		if err != nil {
+			t.Fatalf("NormalizeGame returned error: %v", err)
+		}
+		normalized = append(normalized, ng)
+	}
+
+	normalized = ResolveCollisions(normalized, opts)
+
+	displayLen := opts.EffectiveDisplayLen()
+	seen := make(map[string]string)
+	for _, ng := range normalized {
+		prefix := displayPrefix(ng.Name.Normalized, displayLen)
+		if other, dup := seen[prefix]; dup {
+			t.Fatalf("%q and %q still look identical in the menu", other, ng.Name.Normalized)
+		}
+		seen[prefix] = ng.Name.Normalized
+	}
+	if got := normalized[3].Name.Normalized; got != "aaaaaaaaaa bbbbbbbbbb cccccccc~2" {
+		t.Fatalf("expected the second shortened name to take the next suffix, got %q", got)
+	}
+}
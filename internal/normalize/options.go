@@ -15,3 +15,14 @@ func (o Options) EffectiveMaxLen() int {
 	}
 	return model.ProfileFor(o.Target).MaxNameLen
 }
+
+// EffectiveDisplayLen resolves how many leading characters the target menu shows.
+// Zero means the menu shows the whole name, so uniqueness is checked on the full name.
+func (o Options) EffectiveDisplayLen() int {
+	display := model.ProfileFor(o.Target).DisplayNameLen
+	maxLen := o.EffectiveMaxLen()
+	if display <= 0 || (maxLen > 0 && display >= maxLen) {
+		return 0
+	}
+	return display
+}
@@ -0,0 +1,107 @@
+package planfile
+
+import (
+	"path"
+	"slices"
+	"sort"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+// Move is a file whose destination changed between two plans.
+type Move struct {
+	From layout.PlannedFile `json:"from"`
+	To   layout.PlannedFile `json:"to"`
+}
+
+// Diff lists how a newer plan differs from an older one.
+type Diff struct {
+	Added   []layout.PlannedFile `json:"added,omitempty"`
+	Removed []layout.PlannedFile `json:"removed,omitempty"`
+	Moved   []Move               `json:"moved,omitempty"`
+	Changed []Move               `json:"changed,omitempty"` // Same file, different source, size or generated content
+}
+
+// Empty reports whether the plans place the same files in the same places.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Moved) == 0 && len(d.Changed) == 0
+}
+
+// Compare matches files across two plans by game and variant, so a renamed destination shows up
+// as a move rather than a removal plus an addition, and a renamed source file as a change. Files
+// of one variant (the planned file and its siblings) are paired by source file name first, then
+// by destination; the rest are removed or added. A file that moved and changed source is listed
+// under both.
+func Compare(older, newer File) Diff {
+	oldByVariant := make(map[string][]layout.PlannedFile)
+	var variants []string
+	for _, f := range older.Files {
+		k := variantKey(f)
+		if _, ok := oldByVariant[k]; !ok {
+			variants = append(variants, k)
+		}
+		oldByVariant[k] = append(oldByVariant[k], f)
+	}
+	newByVariant := make(map[string][]layout.PlannedFile)
+	for _, f := range newer.Files {
+		k := variantKey(f)
+		if _, ok := newByVariant[k]; !ok {
+			if _, inOld := oldByVariant[k]; !inOld {
+				variants = append(variants, k)
+			}
+		}
+		newByVariant[k] = append(newByVariant[k], f)
+	}
+
+	var d Diff
+	for _, k := range variants {
+		pairs, removed, added := pairFiles(oldByVariant[k], newByVariant[k])
+		d.Removed = append(d.Removed, removed...)
+		d.Added = append(d.Added, added...)
+		for _, m := range pairs {
+			if m.From.Path != m.To.Path {
+				d.Moved = append(d.Moved, m)
+			}
+			if m.From.Source != m.To.Source || m.From.Size != m.To.Size || m.From.Body != m.To.Body {
+				d.Changed = append(d.Changed, m)
+			}
+		}
+	}
+
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Path < d.Added[j].Path })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Path < d.Removed[j].Path })
+	sort.Slice(d.Moved, func(i, j int) bool { return d.Moved[i].To.Path < d.Moved[j].To.Path })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].To.Path < d.Changed[j].To.Path })
+	return d
+}
+
+// pairFiles matches one variant's files across two plans, returning the pairs and the files
+// left over on either side.
+func pairFiles(older, newer []layout.PlannedFile) (pairs []Move, removed, added []layout.PlannedFile) {
+	oldLeft := append([]layout.PlannedFile(nil), older...)
+	newLeft := append([]layout.PlannedFile(nil), newer...)
+	match := func(same func(a, b layout.PlannedFile) bool) {
+		var rest []layout.PlannedFile
+		for _, f := range newLeft {
+			i := slices.IndexFunc(oldLeft, func(prev layout.PlannedFile) bool { return same(prev, f) })
+			if i < 0 {
+				rest = append(rest, f)
+				continue
+			}
+			pairs = append(pairs, Move{From: oldLeft[i], To: f})
+			oldLeft = slices.Delete(oldLeft, i, i+1)
+		}
+		newLeft = rest
+	}
+
+	// siblings are told apart by their source; generated files have none and a variant ID of
+	// their own (e.g. "-mgl")
+	match(func(a, b layout.PlannedFile) bool { return path.Base(a.Source) == path.Base(b.Source) })
+	match(func(a, b layout.PlannedFile) bool { return a.Path == b.Path })
+	return pairs, oldLeft, newLeft
+}
+
+// variantKey identifies the planned variant a file belongs to, independently of where it is placed.
+func variantKey(f layout.PlannedFile) string {
+	return f.GameID + "\x00" + f.VariantID
+}
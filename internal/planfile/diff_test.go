@@ -0,0 +1,81 @@
+package planfile
+
+import (
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+)
+
+func TestCompareMatchesMovesByVariant(t *testing.T) {
+	older := File{Files: []layout.PlannedFile{
+		{GameID: "elite", VariantID: "elite-0", Source: "Elite/elite.prg", Path: "e/elite/elite.prg", Size: 10},
+		{GameID: "hero", VariantID: "hero-0", Source: "Hero/hero.d64", Path: "h/hero/hero.d64", Size: 20},
+		{GameID: "zaxxon", VariantID: "zaxxon-0", Source: "Zaxxon/zaxxon.crt", Path: "z/zaxxon/zaxxon.crt", Size: 30},
+	}}
+	newer := File{Files: []layout.PlannedFile{
+		{GameID: "elite", VariantID: "elite-0", Source: "Elite/elite.prg", Path: "e/elite.prg", Size: 10},
+		{GameID: "hero", VariantID: "hero-0", Source: "Hero/hero.d64", Path: "h/hero/hero.d64", Size: 21},
+		{GameID: "paradroid", VariantID: "paradroid-0", Source: "Paradroid/paradroid.d64", Path: "p/paradroid/paradroid.d64", Size: 40},
+	}}
+
+	d := Compare(older, newer)
+
+	if len(d.Added) != 1 || d.Added[0].GameID != "paradroid" {
+		t.Fatalf("unexpected added: %+v", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].GameID != "zaxxon" {
+		t.Fatalf("unexpected removed: %+v", d.Removed)
+	}
+	if len(d.Moved) != 1 || d.Moved[0].From.Path != "e/elite/elite.prg" || d.Moved[0].To.Path != "e/elite.prg" {
+		t.Fatalf("unexpected moved: %+v", d.Moved)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].To.GameID != "hero" {
+		t.Fatalf("unexpected changed: %+v", d.Changed)
+	}
+	if Compare(older, older).Empty() != true {
+		t.Fatalf("a plan compared with itself should be empty")
+	}
+}
+
+func TestCompareReportsRenamedSourceAsChange(t *testing.T) {
+	older := File{Files: []layout.PlannedFile{
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/Ultima 1.d64", Path: "u/ultima/disk1.d64", Size: 10},
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/extra.d64", Path: "u/ultima/extra.d64", Size: 5},
+	}}
+	newer := File{Files: []layout.PlannedFile{
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/Ultima I.d64", Path: "u/ultima/disk1.d64", Size: 10},
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/extra.d64", Path: "u/ultima/extra.d64", Size: 5},
+	}}
+
+	d := Compare(older, newer)
+
+	if len(d.Added) != 0 || len(d.Removed) != 0 || len(d.Moved) != 0 {
+		t.Fatalf("a renamed source should not add, remove or move files: %+v", d)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].From.Source != "Ultima/Ultima 1.d64" || d.Changed[0].To.Source != "Ultima/Ultima I.d64" {
+		t.Fatalf("unexpected changed: %+v", d.Changed)
+	}
+}
+
+func TestCompareDoesNotPairUnrelatedSiblings(t *testing.T) {
+	older := File{Files: []layout.PlannedFile{
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/disk1.d64", Path: "u/ultima/disk1.d64", Size: 10},
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/disk2.d64", Path: "u/ultima/disk2.d64", Size: 10},
+	}}
+	newer := File{Files: []layout.PlannedFile{
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/disk1.d64", Path: "u/ultima/disk1.d64", Size: 10},
+		{GameID: "ultima", VariantID: "ultima-0", Source: "Ultima/extra.prg", Path: "u/ultima/extra.prg", Size: 3},
+	}}
+
+	d := Compare(older, newer)
+
+	if len(d.Moved) != 0 || len(d.Changed) != 0 {
+		t.Fatalf("an unrelated sibling should not count as moved or changed: %+v", d)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Path != "u/ultima/disk2.d64" {
+		t.Fatalf("unexpected removed: %+v", d.Removed)
+	}
+	if len(d.Added) != 1 || d.Added[0].Path != "u/ultima/extra.prg" {
+		t.Fatalf("unexpected added: %+v", d.Added)
+	}
+}
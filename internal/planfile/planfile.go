@@ -0,0 +1,87 @@
+package planfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// FormatVersion is the plan file format this build reads and writes.
+const FormatVersion = 1
+
+// File is a serialized, resolved layout plan that can be reviewed, edited and applied later.
+// Paths are relative: sources to the input root, destinations to the output root.
+type File struct {
+	Version     int                  `json:"version"`
+	Target      model.TargetDevice   `json:"target"`
+	SheetSHA256 string               `json:"sheetSha256"`
+	Files       []layout.PlannedFile `json:"files"`
+}
+
+// New wraps resolved planned files in a plan file of the current format version.
+func New(target model.TargetDevice, sheetSHA256 string, files []layout.PlannedFile) File {
+	return File{Version: FormatVersion, Target: target, SheetSHA256: sheetSHA256, Files: files}
+}
+
+// Encode writes a plan file as indented JSON.
+func Encode(w io.Writer, f File) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return fmt.Errorf("encode plan: %w", err)
+	}
+	return nil
+}
+
+// Write saves a plan file to path.
+func Write(path string, f File) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create plan: %w", err)
+	}
+	if err := Encode(out, f); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Read loads a plan file and checks its format version.
+func Read(path string) (File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("read plan: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("parse plan %s: %w", path, err)
+	}
+	if f.Version != FormatVersion {
+		return File{}, fmt.Errorf("plan %s has format version %d; this build reads version %d", path, f.Version, FormatVersion)
+	}
+	if !model.KnownTarget(f.Target) {
+		return File{}, fmt.Errorf("plan %s targets unknown device %q (load its profile with --profiles)", path, f.Target)
+	}
+	return f, nil
+}
+
+// SheetChecksum returns the hex SHA-256 of the sheet a plan is built from.
+func SheetChecksum(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open sheet: %w", err)
+	}
+	defer in.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, in); err != nil {
+		return "", fmt.Errorf("hash sheet: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,62 @@
+package planfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sheet := filepath.Join(dir, "sheet.csv")
+	if err := os.WriteFile(sheet, []byte("abc"), 0o644); err != nil {
+		t.Fatalf("write sheet: %v", err)
+	}
+	sum, err := SheetChecksum(sheet)
+	if err != nil {
+		t.Fatalf("SheetChecksum returned error: %v", err)
+	}
+	if sum != "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad" {
+		t.Fatalf("unexpected checksum %s", sum)
+	}
+
+	files := []layout.PlannedFile{{GameID: "g1", VariantID: "g1-0", Source: "G/g.prg", Path: "g/g.prg", Resolved: true, Size: 3}}
+	path := filepath.Join(dir, "plan.json")
+	if err := Write(path, New(model.TargetUltimate, sum, files)); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read plan: %v", err)
+	}
+	// file entries use the same camelCase keys as the header and leave out empty fields
+	if !strings.Contains(string(raw), `"gameId": "g1"`) || strings.Contains(string(raw), `"body"`) || strings.Contains(string(raw), `"settings"`) {
+		t.Fatalf("unexpected plan encoding:\n%s", raw)
+	}
+
+	f, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if f.Version != FormatVersion || f.Target != model.TargetUltimate || f.SheetSHA256 != sum {
+		t.Fatalf("unexpected header: %+v", f)
+	}
+	if len(f.Files) != 1 || f.Files[0] != files[0] {
+		t.Fatalf("unexpected files: %+v", f.Files)
+	}
+}
+
+func TestReadRejectsOtherVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(path, []byte(`{"version":99,"target":"ultimate","files":[]}`), 0o644); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+	if _, err := Read(path); err == nil {
+		t.Fatalf("expected version error")
+	}
+}
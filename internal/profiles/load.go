@@ -0,0 +1,161 @@
+package profiles
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wazp/c64dreams-tool/internal/launcher"
+	"github.com/wazp/c64dreams-tool/internal/layout"
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+// file is the on-disk shape of a profiles config.
+type file struct {
+	Profiles []json.RawMessage `json:"profiles"`
+}
+
+// entry holds the fields read before a profile is decoded onto its base.
+type entry struct {
+	Name string `json:"name"`
+	Base string `json:"base"`
+}
+
+// Load reads user-defined target profiles from a JSON file.
+// A profile may name a built-in "base" target; fields it omits are inherited from that base.
+func Load(path string) ([]model.TargetProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read profiles: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates profiles from JSON data.
+func Parse(data []byte) ([]model.TargetProfile, error) {
+	var f file
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("parse profiles: %w", err)
+	}
+
+	seen := make(map[model.TargetDevice]struct{})
+	profiles := make([]model.TargetProfile, 0, len(f.Profiles))
+	for i, raw := range f.Profiles {
+		var e entry
+		if err := json.Unmarshal(raw, &e); err != nil {
+			return nil, fmt.Errorf("profile %d: %w", i, err)
+		}
+
+		profile := model.TargetProfile{MaxNameLen: 16}
+		if e.Base != "" {
+			base := model.TargetDevice(e.Base)
+			if !model.KnownTarget(base) {
+				return nil, fmt.Errorf("profile %q: unknown base target %q", e.Name, e.Base)
+			}
+			profile = model.ProfileFor(base)
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		var overlay struct {
+			*model.TargetProfile
+			Base string `json:"base"`
+		}
+		overlay.TargetProfile = &profile
+		if err := dec.Decode(&overlay); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", e.Name, err)
+		}
+
+		if err := Validate(profile); err != nil {
+			return nil, err
+		}
+		if _, dup := seen[profile.Target]; dup {
+			return nil, fmt.Errorf("profile %q defined more than once", profile.Target)
+		}
+		seen[profile.Target] = struct{}{}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, nil
+}
+
+// Validate checks that a profile describes a usable target.
+func Validate(p model.TargetProfile) error {
+	if p.Target == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if p.MaxNameLen <= 0 {
+		return fmt.Errorf("profile %q: maxNameLen must be positive", p.Target)
+	}
+	if p.DisplayNameLen < 0 {
+		return fmt.Errorf("profile %q: displayNameLen must be zero or positive", p.Target)
+	}
+	if p.MaxEntriesPerDir < 0 {
+		return fmt.Errorf("profile %q: maxEntriesPerDir must be zero or positive", p.Target)
+	}
+	if p.Layout.AlphaBucketSize < 0 {
+		return fmt.Errorf("profile %q: layout alphaBucketSize must be zero or positive", p.Target)
+	}
+	if p.Layout.AlphaDepth < 0 {
+		return fmt.Errorf("profile %q: layout alphaDepth must be zero or positive", p.Target)
+	}
+
+	switch p.Charset {
+	case "", model.CharsetASCII, model.CharsetUnicode:
+	default:
+		return fmt.Errorf("profile %q: invalid charset %q (expected ascii or unicode)", p.Target, p.Charset)
+	}
+
+	if _, err := layout.ParseGroupings(p.Layout.GroupBy); err != nil {
+		return fmt.Errorf("profile %q: layout groupBy: %w", p.Target, err)
+	}
+
+	if err := layout.ValidateMediaDirs(p.Layout.MediaDirs); err != nil {
+		return fmt.Errorf("profile %q: layout mediaDirs: %w", p.Target, err)
+	}
+
+	if p.Layout.PathTemplate != "" {
+		if _, err := layout.ParseTemplate(p.Layout.PathTemplate); err != nil {
+			return fmt.Errorf("profile %q: %w", p.Target, err)
+		}
+		if p.Layout.AlphaDepth > 1 {
+			return fmt.Errorf("profile %q: layout alphaDepth cannot be combined with pathTemplate", p.Target)
+		}
+	}
+
+	if !launcher.Known(p.Launcher) {
+		return fmt.Errorf("profile %q: unknown launcher %q", p.Target, p.Launcher)
+	}
+
+	for _, ct := range p.ContentTypes {
+		switch ct {
+		case model.ContentDisk, model.ContentTape, model.ContentPrg, model.ContentZip, model.ContentCart:
+		default:
+			return fmt.Errorf("profile %q: invalid content type %q", p.Target, ct)
+		}
+		// content the device accepts must come in a file it can open
+		if p.ExtensionFor(ct) == "" {
+			return fmt.Errorf("profile %q: supports %s content but none of its extensions (%s)", p.Target, ct, strings.Join(ct.Extensions(), ", "))
+		}
+	}
+
+	return nil
+}
+
+// LoadAndRegister loads profiles from path and registers each one as a selectable target.
+func LoadAndRegister(path string) error {
+	profiles, err := Load(path)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		if err := model.RegisterProfile(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
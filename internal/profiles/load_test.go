@@ -0,0 +1,99 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/wazp/c64dreams-tool/pkg/model"
+)
+
+func TestParseInheritsFromBase(t *testing.T) {
+	data := []byte(`{
+  "profiles": [
+    {
+      "name": "sd2iec-lfn",
+      "base": "sd2iec",
+      "maxNameLen": 64,
+      "contentTypes": ["disk", "prg"],
+      "maxEntriesPerDir": 100,
+      "layout": {"baseDir": "games", "groupAlpha": true}
+    }
+  ]
+}`)
+
+	profiles, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(profiles) != 1 {
+		t.Fatalf("expected 1 profile, got %d", len(profiles))
+	}
+
+	p := profiles[0]
+	if p.Target != "sd2iec-lfn" || p.MaxNameLen != 64 {
+		t.Fatalf("unexpected profile: %+v", p)
+	}
+	if !p.ForceLowercase {
+		t.Fatalf("expected forceLowercase inherited from base")
+	}
+	if len(p.ContentTypes) != 2 || p.MaxEntriesPerDir != 100 {
+		t.Fatalf("unexpected capabilities: %+v", p)
+	}
+	if p.Layout.BaseDir != "games" || !p.Layout.GroupByAlpha {
+		t.Fatalf("unexpected layout defaults: %+v", p.Layout)
+	}
+}
+
+func TestParseRejectsInvalidProfiles(t *testing.T) {
+	cases := []struct {
+		name string
+		data string
+	}{
+		{"missing-name", `{"profiles":[{"maxNameLen":16}]}`},
+		{"unknown-base", `{"profiles":[{"name":"x","base":"nope"}]}`},
+		{"bad-charset", `{"profiles":[{"name":"x","maxNameLen":16,"charset":"ebcdic"}]}`},
+		{"bad-content", `{"profiles":[{"name":"x","maxNameLen":16,"contentTypes":["floppy"]}]}`},
+		{"unknown-field", `{"profiles":[{"name":"x","maxNameLen":16,"maxLen":3}]}`},
+		{"bad-grouping", `{"profiles":[{"name":"x","maxNameLen":16,"layout":{"groupBy":["color"]}}]}`},
+		{"bad-media-group", `{"profiles":[{"name":"x","maxNameLen":16,"layout":{"mediaDirs":{"floppy":"disks"}}}]}`},
+		{"bad-media-dir", `{"profiles":[{"name":"x","maxNameLen":16,"layout":{"mediaDirs":{"disks":"a/b"}}}]}`},
+		{"alpha-depth-with-template", `{"profiles":[{"name":"x","maxNameLen":16,"layout":{"alphaDepth":2,"pathTemplate":"{alpha}/{name}.{ext}"}}]}`},
+		{"content-without-extension", `{"profiles":[{"name":"x","maxNameLen":16,"contentTypes":["tape"],"extensions":["d64"]}]}`},
+		{"duplicate", `{"profiles":[{"name":"x","maxNameLen":16},{"name":"x","maxNameLen":8}]}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.data)); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestRegisteredProfileIsSelectable(t *testing.T) {
+	profiles, err := Parse([]byte(`{"profiles":[{"name":"ultimate-test","base":"ultimate","charset":"unicode"}]}`))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if err := model.RegisterProfile(profiles[0]); err != nil {
+		t.Fatalf("RegisterProfile returned error: %v", err)
+	}
+
+	if !model.KnownTarget("ultimate-test") {
+		t.Fatalf("expected registered profile to be known")
+	}
+	if got := model.ProfileFor("ultimate-test"); got.MaxNameLen != 255 || got.Charset != model.CharsetUnicode {
+		t.Fatalf("unexpected registered profile: %+v", got)
+	}
+	if err := model.RegisterProfile(model.TargetProfile{Target: model.TargetSD2IEC, MaxNameLen: 8}); err == nil {
+		t.Fatalf("expected built-in target to be protected")
+	}
+}
+
+func TestBuiltinProfilesAreConsistent(t *testing.T) {
+	for _, target := range model.Targets() {
+		if err := Validate(model.ProfileFor(target)); err != nil {
+			t.Fatalf("built-in profile %s: %v", target, err)
+		}
+	}
+}
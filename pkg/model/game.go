@@ -28,9 +28,18 @@ type Game struct {
 	Title          string // Display title from metadata
 	NormalizedName string // Canonical name used for output layout
 	Region         Region // Primary region for the game
+	Metadata       Metadata
 	Variants       []Variant
 }
 
+// Metadata holds descriptive sheet columns used for grouping; empty when the sheet lacks them.
+type Metadata struct {
+	Genre     string
+	Year      string
+	Publisher string
+	Players   string
+}
+
 // Variant captures a specific playable build of a game (disk, tape, crack, etc.).
 type Variant struct {
 	Label           string       // Human-friendly label like "Disk", "Tape", or crack info
@@ -39,16 +48,26 @@ type Variant struct {
 	ContentType     ContentType
 	SourcePath      string // Path to archive or file inside the source tree
 	Notes           string // Free-form context for rules/normalization
+	Settings        Settings
+}
+
+// Settings captures how a variant should be launched, as configured by the C64 Dreams sheet.
+type Settings struct {
+	JoystickPort int  // 1 or 2; zero when the sheet does not say
+	TrueDrive    bool // Needs true drive emulation
+	Autowarp     bool // Warp through loading when autostarting
 }
 
 // NormalizedName captures the result of a name normalization pass.
 type NormalizedName struct {
-	Original       string
-	Normalized     string
-	Truncated      bool
-	Collision      bool
-	CollisionGroup string
-	CollisionIndex int
+	Original         string
+	Normalized       string
+	Truncated        bool
+	DisplayTruncated bool // Name runs past the characters the target menu shows
+	Collision        bool
+	DisplayCollision bool // Name only looked identical within the menu-visible prefix
+	CollisionGroup   string
+	CollisionIndex   int
 }
 
 // NormalizedVariant describes a variant ready for layout decisions without filesystem details.
@@ -59,6 +78,7 @@ type NormalizedVariant struct {
 	ContentType     ContentType
 	SourcePath      string
 	Notes           string
+	Settings        Settings
 }
 
 // NormalizedGame is the normalized representation of a Game with collision metadata.
@@ -68,5 +88,6 @@ type NormalizedGame struct {
 	Name     NormalizedName
 	Region   Region
 	Target   TargetDevice
+	Metadata Metadata
 	Variants []NormalizedVariant
 }
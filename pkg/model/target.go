@@ -1,5 +1,12 @@
 package model
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
 // TargetDevice enumerates supported hardware targets.
 type TargetDevice string
 
@@ -8,29 +15,213 @@ const (
 	TargetPi1541      TargetDevice = "pi1541"
 	TargetKungFuFlash TargetDevice = "kungfuflash"
 	TargetUltimate    TargetDevice = "ultimate"
+	TargetTheC64      TargetDevice = "thec64"
+	TargetMiSTer      TargetDevice = "mister"
+	TargetVICE        TargetDevice = "vice"
+)
+
+// Charset names the characters a target can show in file names.
+type Charset string
+
+const (
+	CharsetASCII   Charset = "ascii"   // Letters and digits from plain ASCII only
+	CharsetUnicode Charset = "unicode" // Any Unicode letter or digit
 )
 
 // TargetProfile centralizes hardware constraints.
 type TargetProfile struct {
-	Target         TargetDevice
-	MaxNameLen     int
-	DisplayNameLen int
-	Notes          string
-	ForceLowercase bool
+	Target           TargetDevice  `json:"name"`
+	MaxNameLen       int           `json:"maxNameLen"`
+	DisplayNameLen   int           `json:"displayNameLen,omitempty"`
+	Notes            string        `json:"notes,omitempty"`
+	ForceLowercase   bool          `json:"forceLowercase,omitempty"`
+	Charset          Charset       `json:"charset,omitempty"`          // Empty means ASCII
+	ContentTypes     []ContentType `json:"contentTypes,omitempty"`     // Empty means every content type
+	Extensions       []string      `json:"extensions,omitempty"`       // File extensions the device opens; empty means any
+	MaxEntriesPerDir int           `json:"maxEntriesPerDir,omitempty"` // Zero means unlimited
+	Launcher         string        `json:"launcher,omitempty"`         // Generator for per-game launcher files, e.g. "mister"
+	Layout           ProfileLayout `json:"layout"`
 }
 
+// ProfileLayout holds the layout a target uses unless flags override it.
+type ProfileLayout struct {
+	BaseDir         string            `json:"baseDir,omitempty"`
+	GroupBy         []string          `json:"groupBy,omitempty"` // Grouping strategies in order; takes precedence over the switches below
+	GroupByMedia    bool              `json:"groupMedia,omitempty"`
+	GroupByAlpha    bool              `json:"groupAlpha,omitempty"`
+	AlphaBucketSize int               `json:"alphaBucketSize,omitempty"`
+	AlphaDepth      int               `json:"alphaDepth,omitempty"`    // Nested alpha levels (a/ab/abc) sized to fit one screen
+	PathTemplate    string            `json:"pathTemplate,omitempty"`  // Replaces grouping, e.g. "{alpha}/{name}.{ext}"
+	FilenameFlags   bool              `json:"filenameFlags,omitempty"` // Encode launch settings in file names (TheC64 style)
+	Flat            bool              `json:"flat,omitempty"`          // Single-file games go directly in their bucket
+	MediaDirs       map[string]string `json:"mediaDirs,omitempty"`     // Folder per media group, e.g. {"disks": "DISKS", "prg": "carts", "cart": "carts"}
+}
+
+var (
+	profilesMu     sync.RWMutex
+	customProfiles = map[TargetDevice]TargetProfile{}
+)
+
 // ProfileFor returns the constraints for a target device.
 func ProfileFor(target TargetDevice) TargetProfile {
+	profilesMu.RLock()
+	custom, ok := customProfiles[target]
+	profilesMu.RUnlock()
+	if ok {
+		return custom
+	}
+
+	if profile, ok := builtinProfile(target); ok {
+		return profile
+	}
+	return TargetProfile{Target: target, MaxNameLen: 16}
+}
+
+// RegisterProfile makes a user-defined profile selectable by its target name.
+// Built-in targets cannot be replaced; derive from them instead.
+func RegisterProfile(profile TargetProfile) error {
+	if profile.Target == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if _, ok := builtinProfile(profile.Target); ok {
+		return fmt.Errorf("profile %q shadows a built-in target", profile.Target)
+	}
+
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	customProfiles[profile.Target] = profile
+	return nil
+}
+
+// KnownTarget reports whether a target is built in or has been registered.
+func KnownTarget(target TargetDevice) bool {
+	if _, ok := builtinProfile(target); ok {
+		return true
+	}
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	_, ok := customProfiles[target]
+	return ok
+}
+
+// Targets lists the built-in targets followed by registered ones in name order.
+func Targets() []TargetDevice {
+	targets := []TargetDevice{TargetSD2IEC, TargetPi1541, TargetKungFuFlash, TargetUltimate, TargetTheC64, TargetMiSTer, TargetVICE}
+
+	profilesMu.RLock()
+	custom := make([]TargetDevice, 0, len(customProfiles))
+	for t := range customProfiles {
+		custom = append(custom, t)
+	}
+	profilesMu.RUnlock()
+
+	sort.Slice(custom, func(i, j int) bool { return custom[i] < custom[j] })
+	return append(targets, custom...)
+}
+
+// SupportsContent reports whether the device can use a content type.
+// Unknown content is always allowed because it cannot be judged before the source is found.
+func (p TargetProfile) SupportsContent(ct ContentType) bool {
+	if len(p.ContentTypes) == 0 || ct == ContentUnknown || ct == "" {
+		return true
+	}
+	for _, allowed := range p.ContentTypes {
+		if allowed == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsExtension reports whether the device can open files with the given extension.
+func (p TargetProfile) SupportsExtension(ext string) bool {
+	if len(p.Extensions) == 0 {
+		return true
+	}
+	ext = strings.TrimPrefix(strings.ToLower(ext), ".")
+	for _, allowed := range p.Extensions {
+		if strings.EqualFold(allowed, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentExtensions lists the file extensions of each content type, the usual one first.
+var contentExtensions = map[ContentType][]string{
+	ContentDisk: {"d64", "d71", "d81", "g64"},
+	ContentTape: {"tap", "t64"},
+	ContentCart: {"crt", "ef"},
+	ContentPrg:  {"prg"},
+	ContentZip:  {"zip"},
+}
+
+// Extensions returns the file extensions of a content type, the usual one first.
+func (ct ContentType) Extensions() []string {
+	return contentExtensions[ct]
+}
+
+// ExtensionFor returns the extension the device uses for a content type: the first of the
+// content's extensions it opens, or "" when it opens none of them.
+func (p TargetProfile) ExtensionFor(ct ContentType) string {
+	for _, ext := range ct.Extensions() {
+		if p.SupportsExtension(ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+func builtinProfile(target TargetDevice) (TargetProfile, bool) {
 	switch target {
 	case TargetSD2IEC:
-		return TargetProfile{Target: TargetSD2IEC, MaxNameLen: 16, Notes: "Commodore DOS filename length", ForceLowercase: true}
+		return TargetProfile{
+			Target: TargetSD2IEC, MaxNameLen: 16, Notes: "Commodore DOS filename length", ForceLowercase: true,
+			MaxEntriesPerDir: 144, // a full 1541 directory; larger listings crawl over IEC
+			ContentTypes:     []ContentType{ContentDisk, ContentPrg},
+			Extensions:       []string{"d64", "d71", "d81", "prg"},
+		}, true
 	case TargetPi1541:
-		return TargetProfile{Target: TargetPi1541, MaxNameLen: 16, Notes: "Behaves like 1541/Commodore DOS", ForceLowercase: true}
+		return TargetProfile{
+			Target: TargetPi1541, MaxNameLen: 16, Notes: "Behaves like 1541/Commodore DOS", ForceLowercase: true,
+			MaxEntriesPerDir: 144,
+			ContentTypes:     []ContentType{ContentDisk, ContentPrg},
+			Extensions:       []string{"d64", "g64", "d71", "d81", "prg"},
+		}, true
 	case TargetKungFuFlash:
-		return TargetProfile{Target: TargetKungFuFlash, MaxNameLen: 255, DisplayNameLen: 32, Notes: "Menu display truncates around 32 chars", ForceLowercase: true}
+		return TargetProfile{
+			Target: TargetKungFuFlash, MaxNameLen: 255, DisplayNameLen: 32, Notes: "Menu display truncates around 32 chars", ForceLowercase: true,
+			ContentTypes: []ContentType{ContentCart, ContentPrg, ContentDisk, ContentTape},
+			Extensions:   []string{"crt", "prg", "d64", "t64"},
+		}, true
 	case TargetUltimate:
-		return TargetProfile{Target: TargetUltimate, MaxNameLen: 255, Notes: "Filesystem long filename typical maximum"}
+		return TargetProfile{
+			Target: TargetUltimate, MaxNameLen: 255, Notes: "Filesystem long filename typical maximum",
+			ContentTypes: []ContentType{ContentDisk, ContentTape, ContentCart, ContentPrg},
+			Extensions:   []string{"d64", "d71", "d81", "g64", "tap", "t64", "crt", "prg"},
+		}, true
+	case TargetTheC64:
+		return TargetProfile{
+			Target: TargetTheC64, MaxNameLen: 255, Notes: "TheC64 Maxi/Mini USB loading; launch settings come from filename flags",
+			ContentTypes: []ContentType{ContentDisk, ContentTape, ContentCart, ContentPrg},
+			Extensions:   []string{"d64", "t64", "tap", "crt", "prg"},
+			Layout:       ProfileLayout{GroupByAlpha: true, FilenameFlags: true},
+		}, true
+	case TargetMiSTer:
+		return TargetProfile{
+			Target: TargetMiSTer, MaxNameLen: 255, Notes: "MiSTer FPGA C64 core; games launch from MGL files",
+			ContentTypes: []ContentType{ContentDisk, ContentTape, ContentCart, ContentPrg},
+			Extensions:   []string{"d64", "t64", "tap", "crt", "prg"},
+			Launcher:     "mister",
+			Layout:       ProfileLayout{BaseDir: "games/C64", GroupByAlpha: true},
+		}, true
+	case TargetVICE:
+		return TargetProfile{
+			Target: TargetVICE, MaxNameLen: 255, Notes: "VICE emulator; per-game option files and fliplists for testing builds",
+			Launcher: "vice",
+			Layout:   ProfileLayout{GroupByAlpha: true},
+		}, true
 	default:
-		return TargetProfile{Target: target, MaxNameLen: 16}
+		return TargetProfile{}, false
 	}
 }